@@ -0,0 +1,80 @@
+package digest
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"strings"
+	"sync"
+)
+
+// algorithm is a registered hash algorithm
+type algorithm struct {
+	name string
+	new  func() hash.Hash
+}
+
+var (
+	algorithms   = map[string]*algorithm{}
+	algorithmsMu sync.RWMutex
+)
+
+func init() {
+	RegisterAlgorithm("MD5", func() hash.Hash { return newMD5() })
+	RegisterAlgorithm("SHA-256", sha256.New)
+	RegisterAlgorithm("SHA-512", sha512.New)
+	RegisterAlgorithm("SHA-512-256", sha512.New512_256)
+}
+
+// RegisterAlgorithm makes a hash algorithm available to CanDigest, Digest, and
+// challenge selection. The name and aliases are matched case-insensitively
+// and replace any existing registrations. Aliases are useful for vendors which
+// use non-standard names such as "SHA256".
+//
+// Algorithms registered as aliases of MD5 are refused when MD5 is disabled,
+// but other names registered with an MD5 implementation are not.
+func RegisterAlgorithm(name string, fn func() hash.Hash, aliases ...string) {
+	if name == "" || fn == nil {
+		panic("digest: RegisterAlgorithm requires a name and hash function")
+	}
+	a := &algorithm{name: strings.ToUpper(name), new: fn}
+	algorithmsMu.Lock()
+	defer algorithmsMu.Unlock()
+	for _, key := range append([]string{name}, aliases...) {
+		algorithms[strings.ToUpper(key)] = a
+	}
+}
+
+// lookupAlgorithm returns the registered algorithm.
+// Challenges without an algorithm use MD5.
+func lookupAlgorithm(name string) (*algorithm, bool) {
+	if name == "" {
+		name = "MD5"
+	}
+	algorithmsMu.RLock()
+	defer algorithmsMu.RUnlock()
+	a, ok := algorithms[strings.ToUpper(name)]
+	return a, ok
+}
+
+// canonical returns the registered name of the algorithm.
+// Unregistered algorithms are upper cased.
+func canonical(name string) string {
+	if a, ok := lookupAlgorithm(name); ok {
+		return a.name
+	}
+	return strings.ToUpper(name)
+}
+
+// newHash returns the hash function for the algorithm
+func newHash(name string) (hash.Hash, error) {
+	if err := checkMD5(name); err != nil {
+		return nil, err
+	}
+	a, ok := lookupAlgorithm(name)
+	if !ok {
+		return nil, fmt.Errorf("digest: unsupported algorithm: %q", name)
+	}
+	return a.new(), nil
+}
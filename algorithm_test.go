@@ -0,0 +1,66 @@
+package digest_test
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"testing"
+
+	"github.com/icholy/digest"
+	"github.com/icholy/digest/digesttest"
+	"gotest.tools/v3/assert"
+)
+
+func TestRegisterAlgorithm(t *testing.T) {
+	digest.RestoreAlgorithms(t)
+	chal := &digest.Challenge{
+		Realm:     "test",
+		Nonce:     "abc",
+		Algorithm: "sha256-vendor",
+		QOP:       []string{"auth"},
+	}
+	assert.Assert(t, !digest.CanDigest(chal))
+	digest.RegisterAlgorithm("X-SHA256", sha256.New, "SHA256-Vendor")
+	assert.Assert(t, digest.CanDigest(chal))
+	opt := digest.Options{
+		Method:   http.MethodGet,
+		URI:      "/",
+		Username: "foo",
+		Password: "bar",
+		Cnonce:   "xyz",
+	}
+	cred, err := digest.Digest(chal, opt)
+	assert.NilError(t, err)
+	assert.Equal(t, cred.Algorithm, "sha256-vendor")
+	// the response matches the standard algorithm
+	chal.Algorithm = "SHA-256"
+	expected, err := digest.Digest(chal, opt)
+	assert.NilError(t, err)
+	assert.Equal(t, cred.Response, expected.Response)
+}
+
+func TestTransportRegisteredAlgorithm(t *testing.T) {
+	digest.RestoreAlgorithms(t)
+	digest.RegisterAlgorithm("SHA-256", sha256.New, "SHA256")
+	s := digesttest.NewUnstartedServer(nil, map[string]string{"foo": "bar"})
+	s.Algorithms = []string{"SHA256"}
+	s.Start()
+	defer s.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username: "foo",
+			Password: "bar",
+			Policy:   &digest.Policy{MinAlgorithm: "SHA-256"},
+		},
+	}
+	getOK(t, client, s.URL)
+}
+
+func TestRestoreAlgorithms(t *testing.T) {
+	chal := &digest.Challenge{Algorithm: "SHA256"}
+	t.Run("register", func(t *testing.T) {
+		digest.RestoreAlgorithms(t)
+		digest.RegisterAlgorithm("SHA-256", sha256.New, "SHA256")
+		assert.Assert(t, digest.CanDigest(chal))
+	})
+	assert.Assert(t, !digest.CanDigest(chal))
+}
@@ -0,0 +1,159 @@
+package digest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/icholy/digest/internal/param"
+)
+
+// Authenticator implements an authentication scheme for a Transport.
+// The Transport takes care of retries, challenge caching, and cookies.
+type Authenticator interface {
+	// Challenge selects a supported challenge from a 401 response.
+	// It returns ErrNoChallenge if the response has no supported challenges.
+	Challenge(res *http.Response) (AuthChallenge, error)
+}
+
+// AuthChallenge is a challenge which was selected by an Authenticator.
+// Challenges are cached and may be used by concurrent requests.
+type AuthChallenge interface {
+	// Authorize sets the Authorization header on the request. The count
+	// is the number of times the challenge has been used, starting at 1.
+	Authorize(req *http.Request, count int) error
+
+	// Next is invoked with the response to an authorized request. It returns
+	// a challenge which replaces this one for future requests, or nil to keep
+	// using this one.
+	Next(res *http.Response) AuthChallenge
+}
+
+// DigestAuth is an Authenticator which implements digest authentication.
+type DigestAuth struct {
+	Username string
+	Password string
+
+	// Digest computes the digest credentials.
+	// If nil, the Digest function is used.
+	Digest func(*http.Request, *Challenge, Options) (*Credentials, error)
+
+	// FindChallenge extracts the challenge from the request headers.
+	// If nil, the FindChallenge function is used.
+	FindChallenge func(http.Header) (*Challenge, error)
+
+	// Policy specifies the minimum requirements for challenges.
+	// If nil, all supported challenges are accepted.
+	Policy *Policy
+
+	// Quirks adjust the credentials for non-compliant servers.
+	Quirks Quirks
+}
+
+// Challenge implements Authenticator
+func (a *DigestAuth) Challenge(res *http.Response) (AuthChallenge, error) {
+	chal, err := selectChallenge(res.Header, a.FindChallenge, a.Policy, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &digestChallenge{chal: chal, digest: a.digest, format: a.format}, nil
+}
+
+// digest creates credentials from the challenge
+func (a *DigestAuth) digest(req *http.Request, chal *Challenge, count int) (*Credentials, error) {
+	opt := Options{
+		Method:   req.Method,
+		URI:      a.Quirks.uri(req),
+		GetBody:  req.GetBody,
+		Count:    count,
+		Username: a.Username,
+		Password: a.Password,
+		Policy:   a.Policy,
+	}
+	if a.Digest != nil {
+		return a.Digest(req, chal, opt)
+	}
+	return Digest(chal, opt)
+}
+
+// format formats the credentials using the quirks
+func (a *DigestAuth) format(cred *Credentials) string {
+	return cred.Format(a.Quirks)
+}
+
+// digestChallenge is the AuthChallenge used for digest authentication
+type digestChallenge struct {
+	chal   *Challenge
+	digest func(*http.Request, *Challenge, int) (*Credentials, error)
+	format func(*Credentials) string
+}
+
+// Authorize implements AuthChallenge
+func (c *digestChallenge) Authorize(req *http.Request, count int) error {
+	cred, err := c.digest(req, c.chal, count)
+	if err != nil {
+		return err
+	}
+	if cred != nil {
+		req.Header.Set("Authorization", c.format(cred))
+	}
+	return nil
+}
+
+// Next implements AuthChallenge by switching to the nextnonce
+// from the Authentication-Info header if there is one.
+func (c *digestChallenge) Next(res *http.Response) AuthChallenge {
+	next := NextNonce(res.Header)
+	if next == "" || next == c.chal.Nonce {
+		return nil
+	}
+	chal := *c.chal
+	chal.Nonce = next
+	chal.Stale = false
+	return &digestChallenge{chal: &chal, digest: c.digest, format: c.format}
+}
+
+// NextNonce returns the nextnonce value from the Authentication-Info header.
+// An empty string is returned if there isn't one.
+func NextNonce(h http.Header) string {
+	info := h.Get("Authentication-Info")
+	if info == "" {
+		return ""
+	}
+	pp, err := param.Parse(info)
+	if err != nil {
+		return ""
+	}
+	for _, p := range pp {
+		if strings.EqualFold(p.Key, "nextnonce") {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// transportAuth is the Authenticator used when Transport.Authenticators is empty.
+// It reads the Transport's configuration when a request is authorized.
+type transportAuth struct {
+	t *Transport
+}
+
+// Challenge implements Authenticator
+func (a transportAuth) Challenge(res *http.Response) (AuthChallenge, error) {
+	pinned := a.t.pin(res)
+	chal, err := selectChallenge(res.Header, a.t.FindChallenge, a.t.Policy, pinned)
+	if err == nil {
+		return &digestChallenge{chal: chal, digest: a.t.digest, format: a.t.format}, nil
+	}
+	// fall back to basic auth if it's allowed and the origin
+	// hasn't been pinned to a digest algorithm
+	if err == ErrNoChallenge && pinned == 0 && a.t.Basic.allows(res.Request) && hasBasic(res.Header) {
+		return basicChallenge(func() BasicAuth {
+			return BasicAuth{
+				Username: a.t.Username,
+				Password: a.t.Password,
+				Policy:   a.t.Basic,
+			}
+		}), nil
+	}
+	return nil, err
+}
@@ -0,0 +1,127 @@
+package digest_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/icholy/digest"
+	"github.com/icholy/digest/digesttest"
+	"gotest.tools/v3/assert"
+)
+
+// tokenAuth is a vendor authentication scheme which
+// echoes the challenge token back to the server
+type tokenAuth struct{}
+
+func (tokenAuth) Challenge(res *http.Response) (digest.AuthChallenge, error) {
+	for _, header := range res.Header.Values("WWW-Authenticate") {
+		if token, ok := strings.CutPrefix(header, "Token "); ok {
+			return tokenChallenge(token), nil
+		}
+	}
+	return nil, digest.ErrNoChallenge
+}
+
+type tokenChallenge string
+
+func (c tokenChallenge) Authorize(req *http.Request, count int) error {
+	req.Header.Set("Authorization", "Token "+string(c))
+	return nil
+}
+
+func (c tokenChallenge) Next(res *http.Response) digest.AuthChallenge {
+	return nil
+}
+
+func TestTransportAuthenticators(t *testing.T) {
+	var challenges int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Token secret" {
+			io.WriteString(w, "Hello World")
+			return
+		}
+		challenges++
+		w.Header().Add("WWW-Authenticate", "Token secret")
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Authenticators: []digest.Authenticator{
+				&digest.DigestAuth{Username: "foo", Password: "bar"},
+				tokenAuth{},
+			},
+		},
+	}
+	for range 3 {
+		getOK(t, client, ts.URL)
+	}
+	assert.Equal(t, challenges, 1)
+}
+
+func TestTransportAuthenticatorsDigest(t *testing.T) {
+	s := digesttest.NewServer(nil, map[string]string{"foo": "bar"})
+	defer s.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Authenticators: []digest.Authenticator{
+				tokenAuth{},
+				&digest.BasicAuth{Username: "foo", Password: "bar", Policy: digest.BasicAlways},
+				&digest.DigestAuth{Username: "foo", Password: "bar"},
+			},
+		},
+	}
+	for range 3 {
+		getOK(t, client, s.URL)
+	}
+	assert.Equal(t, s.Challenges(), 1)
+}
+
+func TestTransportAuthenticatorError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("WWW-Authenticate", `Digest realm="test", nonce="`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Authenticators: []digest.Authenticator{
+				&digest.DigestAuth{Username: "foo", Password: "bar"},
+				tokenAuth{},
+			},
+		},
+	}
+	_, err := client.Get(ts.URL)
+	assert.Assert(t, err != nil)
+	assert.Assert(t, !errors.Is(err, digest.ErrNoChallenge))
+}
+
+func TestTransportNextNonce(t *testing.T) {
+	s := digesttest.NewUnstartedServer(nil, map[string]string{"foo": "bar"})
+	s.NonceUses = 1
+	s.NextNonce = true
+	s.Start()
+	defer s.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username: "foo",
+			Password: "bar",
+		},
+	}
+	for range 5 {
+		getOK(t, client, s.URL)
+	}
+	assert.Equal(t, s.Challenges(), 1)
+	assert.Equal(t, s.Successes(), 5)
+}
+
+func TestNextNonce(t *testing.T) {
+	h := http.Header{}
+	assert.Equal(t, digest.NextNonce(h), "")
+	h.Set("Authentication-Info", `qop=auth, rspauth="d3b07384d113edec49eaa6238ad5ff00", cnonce="0a4f113b", nc=00000001, nextnonce="abc123"`)
+	assert.Equal(t, digest.NextNonce(h), "abc123")
+}
@@ -0,0 +1,77 @@
+package digest
+
+import (
+	"net/http"
+	"strings"
+)
+
+// BasicPolicy controls when a Transport answers Basic challenges
+type BasicPolicy int
+
+const (
+	// BasicNever never answers Basic challenges
+	BasicNever BasicPolicy = iota
+	// BasicTLS answers Basic challenges for https requests only
+	BasicTLS
+	// BasicAlways answers Basic challenges for all requests.
+	// The password is sent in plaintext over http.
+	BasicAlways
+)
+
+// BasicAuth is an Authenticator which implements Basic authentication.
+type BasicAuth struct {
+	Username string
+	Password string
+
+	// Policy controls which requests Basic credentials are sent with.
+	// If zero, credentials are never sent.
+	Policy BasicPolicy
+}
+
+// Challenge implements Authenticator
+func (a *BasicAuth) Challenge(res *http.Response) (AuthChallenge, error) {
+	if !a.Policy.allows(res.Request) || !hasBasic(res.Header) {
+		return nil, ErrNoChallenge
+	}
+	return basicChallenge(func() BasicAuth { return *a }), nil
+}
+
+// basicChallenge is the AuthChallenge used for Basic authentication.
+// It reads the credentials when a request is authorized.
+type basicChallenge func() BasicAuth
+
+// Authorize implements AuthChallenge
+func (c basicChallenge) Authorize(req *http.Request, count int) error {
+	a := c()
+	if a.Policy.allows(req) {
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+	return nil
+}
+
+// Next implements AuthChallenge
+func (c basicChallenge) Next(res *http.Response) AuthChallenge {
+	return nil
+}
+
+// allows returns true if the policy permits sending Basic credentials for the request
+func (p BasicPolicy) allows(req *http.Request) bool {
+	switch p {
+	case BasicTLS:
+		return req.URL.Scheme == "https"
+	case BasicAlways:
+		return true
+	default:
+		return false
+	}
+}
+
+// hasBasic returns true if the headers contain a Basic challenge
+func hasBasic(h http.Header) bool {
+	for _, header := range h.Values("WWW-Authenticate") {
+		if scheme, _, _ := strings.Cut(header, " "); strings.EqualFold(scheme, "Basic") {
+			return true
+		}
+	}
+	return false
+}
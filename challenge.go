@@ -3,6 +3,7 @@ package digest
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"slices"
 	"strings"
@@ -29,8 +30,19 @@ func (c *Challenge) SupportsQOP(qop string) bool {
 	return slices.Contains(c.QOP, qop)
 }
 
-// ParseChallenge parses the WWW-Authenticate header challenge
+// ParseChallenge parses the WWW-Authenticate header challenge.
+// Parameter names are case-insensitive and unknown parameters are ignored.
 func ParseChallenge(s string) (*Challenge, error) {
+	return parseChallenge(s, false)
+}
+
+// ParseChallengeStrict is like ParseChallenge but returns
+// an error if a parameter appears more than once.
+func ParseChallengeStrict(s string) (*Challenge, error) {
+	return parseChallenge(s, true)
+}
+
+func parseChallenge(s string, strict bool) (*Challenge, error) {
 	s, ok := CutPrefix(s)
 	if !ok {
 		return nil, errors.New("digest: invalid challenge prefix")
@@ -39,13 +51,18 @@ func ParseChallenge(s string) (*Challenge, error) {
 	if err != nil {
 		return nil, fmt.Errorf("digest: invalid challenge: %w", err)
 	}
+	if key, ok := param.Duplicate(pp); ok && strict {
+		return nil, fmt.Errorf("digest: invalid challenge: duplicate parameter %q", key)
+	}
 	var c Challenge
 	for _, p := range pp {
-		switch p.Key {
+		switch strings.ToLower(p.Key) {
 		case "realm":
 			c.Realm = p.Value
 		case "domain":
-			c.Domain = strings.Fields(p.Value)
+			if domain := strings.Fields(p.Value); len(domain) > 0 {
+				c.Domain = domain
+			}
 		case "nonce":
 			c.Nonce = p.Value
 		case "algorithm":
@@ -55,7 +72,7 @@ func ParseChallenge(s string) (*Challenge, error) {
 		case "opaque":
 			c.Opaque = p.Value
 		case "qop":
-			c.QOP = strings.Split(p.Value, ",")
+			c.QOP = param.List(p.Value)
 		case "charset":
 			c.Charset = p.Value
 		case "userhash":
@@ -126,23 +143,54 @@ func (c *Challenge) String() string {
 	return Prefix + param.Format(pp...)
 }
 
+// LogValue implements slog.LogValuer. The nonce and opaque values are omitted.
+func (c *Challenge) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("realm", c.Realm),
+		slog.String("algorithm", c.Algorithm),
+		slog.Any("qop", c.QOP),
+		slog.Bool("stale", c.Stale),
+		slog.Bool("userhash", c.Userhash),
+	)
+}
+
 // ErrNoChallenge indicates that no WWW-Authenticate headers were found.
 var ErrNoChallenge = errors.New("digest: no challenge found")
 
 // FindChallenge returns the first supported challenge in the headers
 func FindChallenge(h http.Header) (*Challenge, error) {
-	var last error
+	return findChallenge(h, nil)
+}
+
+// findChallenge returns the first supported challenge in the headers which
+// passes the check. If check is nil, all supported challenges are accepted.
+func findChallenge(h http.Header, check func(*Challenge) error) (*Challenge, error) {
+	var last, rejected error
 	for _, header := range h.Values("WWW-Authenticate") {
 		if !IsDigest(header) {
 			continue
 		}
 		chal, err := ParseChallenge(header)
-		if err == nil && CanDigest(chal) {
-			return chal, nil
-		}
 		if err != nil {
 			last = err
+			continue
 		}
+		if !CanDigest(chal) {
+			if err := checkMD5(chal.Algorithm); err != nil {
+				rejected = err
+			}
+			continue
+		}
+		if check != nil {
+			if err := check(chal); err != nil {
+				rejected = err
+				continue
+			}
+		}
+		return chal, nil
+	}
+	if rejected != nil {
+		return nil, rejected
 	}
 	if last != nil {
 		return nil, last
@@ -34,6 +34,16 @@ func TestChallenge(t *testing.T) {
 				QOP:       []string{"auth"},
 			},
 		},
+		{
+			input:  `Digest Realm="test", NONCE="abc", Algorithm=SHA-256, QoP="auth, auth-int"`,
+			output: `Digest realm="test", nonce="abc", algorithm=SHA-256, qop="auth,auth-int"`,
+			challenge: &Challenge{
+				Realm:     "test",
+				Nonce:     "abc",
+				Algorithm: "SHA-256",
+				QOP:       []string{"auth", "auth-int"},
+			},
+		},
 		{
 			input:  `DIGEST realm="DLI LPC92601002528", nonce="NZAeQHhoCNifFjFa"`,
 			output: `Digest realm="DLI LPC92601002528", nonce="NZAeQHhoCNifFjFa"`,
@@ -57,6 +67,15 @@ func TestChallenge(t *testing.T) {
 	}
 }
 
+func TestParseChallengeStrict(t *testing.T) {
+	input := `Digest realm="test", nonce="abc", Realm="other"`
+	c, err := ParseChallenge(input)
+	assert.NilError(t, err)
+	assert.Equal(t, c.Realm, "other")
+	_, err = ParseChallengeStrict(input)
+	assert.ErrorContains(t, err, `duplicate parameter "Realm"`)
+}
+
 func TestFindChallenge(t *testing.T) {
 	bad1 := &Challenge{
 		Realm:     "test",
@@ -100,3 +119,16 @@ func BenchmarkParseChallenge(b *testing.B) {
 	}
 	challengeResult = chal
 }
+
+func FuzzParseChallenge(f *testing.F) {
+	f.Fuzz(func(t *testing.T, input string) {
+		c, err := ParseChallenge(input)
+		if err != nil {
+			return
+		}
+		output := c.String()
+		c2, err := ParseChallenge(output)
+		assert.NilError(t, err, output)
+		assert.DeepEqual(t, c2, c)
+	})
+}
@@ -0,0 +1,163 @@
+package digest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/icholy/digest"
+	"github.com/icholy/digest/digesttest"
+	"gotest.tools/v3/assert"
+)
+
+func parallel(t testing.TB, client *http.Client, url string, clients, requests int) {
+	var wg sync.WaitGroup
+	for range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range requests {
+				res, err := client.Get(url)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				_, _ = io.Copy(io.Discard, res.Body)
+				res.Body.Close()
+				if res.StatusCode != http.StatusOK {
+					t.Errorf("unexpected status: %s", res.Status)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTransportSerialize(t *testing.T) {
+	s := digesttest.NewUnstartedServer(nil, map[string]string{"foo": "bar"})
+	s.StrictNonceCount = true
+	s.Start()
+	defer s.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username:  "foo",
+			Password:  "bar",
+			Serialize: true,
+		},
+	}
+	// obtain the challenge
+	parallel(t, client, s.URL, 1, 1)
+	// all parallel requests should re-use it
+	parallel(t, client, s.URL, 100, 5)
+	assert.Equal(t, s.Challenges(), 1)
+	assert.Equal(t, s.Successes(), 501)
+}
+
+func TestTransportSerializeDeadline(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	s := digesttest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(entered)
+			<-release
+		}
+	}), map[string]string{"foo": "bar"})
+	defer s.Close()
+	defer close(release)
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username:  "foo",
+			Password:  "bar",
+			Serialize: true,
+		},
+	}
+	// obtain the challenge
+	parallel(t, client, s.URL, 1, 1)
+	// hold the challenge with a slow request
+	done := make(chan error, 1)
+	go func() {
+		res, err := client.Get(s.URL + "/slow")
+		if err == nil {
+			res.Body.Close()
+		}
+		done <- err
+	}()
+	<-entered
+	// a request waiting for the challenge gives up when its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	assert.NilError(t, err)
+	start := time.Now()
+	_, err = client.Do(req)
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+	assert.Assert(t, time.Since(start) < 2*time.Second)
+	release <- struct{}{}
+	assert.NilError(t, <-done)
+}
+
+func TestTransportPool(t *testing.T) {
+	s := digesttest.NewUnstartedServer(nil, map[string]string{"foo": "bar"})
+	s.StrictNonceCount = true
+	s.Start()
+	defer s.Close()
+	var mu sync.Mutex
+	nonces := map[string]bool{}
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username:  "foo",
+			Password:  "bar",
+			Serialize: true,
+			PoolSize:  4,
+			Trace: &digest.ClientTrace{
+				OnCacheHit: func(req *http.Request, chal *digest.Challenge, count int) {
+					mu.Lock()
+					defer mu.Unlock()
+					nonces[chal.Nonce] = true
+				},
+			},
+		},
+	}
+	parallel(t, client, s.URL, 1, 1)
+	parallel(t, client, s.URL, 100, 5)
+	assert.Assert(t, s.Challenges() > 1)
+	assert.Assert(t, len(nonces) > 1)
+	assert.Equal(t, s.Successes(), 501)
+}
+
+func BenchmarkTransportParallel(b *testing.B) {
+	tests := []struct {
+		name      string
+		serialize bool
+		poolSize  int
+	}{
+		{name: "Concurrent"},
+		{name: "Serialize", serialize: true},
+		{name: "SerializePool", serialize: true, poolSize: 8},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			s := digesttest.NewUnstartedServer(nil, map[string]string{"foo": "bar"})
+			s.StrictNonceCount = true
+			s.Start()
+			defer s.Close()
+			client := &http.Client{
+				Transport: &digest.Transport{
+					Username:  "foo",
+					Password:  "bar",
+					Serialize: tt.serialize,
+					PoolSize:  tt.poolSize,
+				},
+			}
+			b.ResetTimer()
+			for range b.N {
+				parallel(b, client, s.URL, 100, 1)
+			}
+			b.ReportMetric(float64(s.Challenges())/float64(b.N), "challenges/op")
+		})
+	}
+}
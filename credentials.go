@@ -3,6 +3,7 @@ package digest
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
 
@@ -24,8 +25,19 @@ type Credentials struct {
 	Userhash  bool
 }
 
-// ParseCredentials parses the Authorization header value into credentials
+// ParseCredentials parses the Authorization header value into credentials.
+// Parameter names are case-insensitive and unknown parameters are ignored.
 func ParseCredentials(s string) (*Credentials, error) {
+	return parseCredentials(s, false)
+}
+
+// ParseCredentialsStrict is like ParseCredentials but returns
+// an error if a parameter appears more than once.
+func ParseCredentialsStrict(s string) (*Credentials, error) {
+	return parseCredentials(s, true)
+}
+
+func parseCredentials(s string, strict bool) (*Credentials, error) {
 	s, ok := CutPrefix(s)
 	if !ok {
 		return nil, errors.New("digest: invalid credentials prefix")
@@ -34,9 +46,12 @@ func ParseCredentials(s string) (*Credentials, error) {
 	if err != nil {
 		return nil, fmt.Errorf("digest: invalid credentials: %w", err)
 	}
+	if key, ok := param.Duplicate(pp); ok && strict {
+		return nil, fmt.Errorf("digest: invalid credentials: duplicate parameter %q", key)
+	}
 	var c Credentials
 	for _, p := range pp {
-		switch p.Key {
+		switch strings.ToLower(p.Key) {
 		case "username":
 			c.Username = p.Value
 		case "realm":
@@ -54,7 +69,7 @@ func ParseCredentials(s string) (*Credentials, error) {
 		case "opaque":
 			c.Opaque = p.Value
 		case "qop":
-			c.QOP = p.Value
+			c.QOP = strings.TrimSpace(p.Value)
 		case "nc":
 			nc, err := strconv.ParseInt(p.Value, 16, 32)
 			if err != nil {
@@ -70,6 +85,11 @@ func ParseCredentials(s string) (*Credentials, error) {
 
 // String formats the credentials into the header format
 func (c *Credentials) String() string {
+	return c.Format(Quirks{})
+}
+
+// Format formats the credentials into the header format using the quirks
+func (c *Credentials) Format(q Quirks) string {
 	var pp []param.Param
 	pp = append(pp,
 		param.Param{
@@ -97,6 +117,7 @@ func (c *Credentials) String() string {
 		pp = append(pp, param.Param{
 			Key:   "algorithm",
 			Value: c.Algorithm,
+			Quote: q.QuoteAlgorithm,
 		})
 	}
 	if c.QOP != "" {
@@ -118,6 +139,7 @@ func (c *Credentials) String() string {
 			param.Param{
 				Key:   "qop",
 				Value: c.QOP,
+				Quote: q.QuoteQOP,
 			},
 			param.Param{
 				Key:   "nc",
@@ -140,3 +162,17 @@ func (c *Credentials) String() string {
 	})
 	return Prefix + param.Format(pp...)
 }
+
+// LogValue implements slog.LogValuer. The response is always redacted.
+func (c *Credentials) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("username", c.Username),
+		slog.String("realm", c.Realm),
+		slog.String("uri", c.URI),
+		slog.String("algorithm", c.Algorithm),
+		slog.String("qop", c.QOP),
+		slog.Int("nc", c.Nc),
+		slog.Bool("userhash", c.Userhash),
+		slog.String("response", "REDACTED"),
+	)
+}
@@ -1,6 +1,9 @@
 package digest
 
 import (
+	"bytes"
+	"log/slog"
+	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
@@ -51,6 +54,64 @@ func TestCredentials(t *testing.T) {
 	}
 }
 
+func TestParseCredentialsStrict(t *testing.T) {
+	input := `Digest USERNAME="foo", realm="test", Nonce="abc", uri="/", qop=" auth ", response="xyz", response="abc"`
+	c, err := ParseCredentials(input)
+	assert.NilError(t, err)
+	assert.Equal(t, c.Username, "foo")
+	assert.Equal(t, c.Nonce, "abc")
+	assert.Equal(t, c.QOP, "auth")
+	_, err = ParseCredentialsStrict(input)
+	assert.ErrorContains(t, err, `duplicate parameter "response"`)
+}
+
+func TestCredentialsFormat(t *testing.T) {
+	cred := &Credentials{
+		Username:  "foo",
+		Realm:     "test",
+		Nonce:     "abc",
+		URI:       "/",
+		Response:  "xyz",
+		Algorithm: "MD5",
+		Cnonce:    "123",
+		QOP:       "auth",
+		Nc:        1,
+	}
+	tests := []struct {
+		quirks Quirks
+		output string
+	}{
+		{
+			quirks: Quirks{},
+			output: `Digest username="foo", realm="test", nonce="abc", uri="/", algorithm=MD5, cnonce="123", qop=auth, nc=00000001, response="xyz"`,
+		},
+		{
+			quirks: QuirksQuoted,
+			output: `Digest username="foo", realm="test", nonce="abc", uri="/", algorithm="MD5", cnonce="123", qop="auth", nc=00000001, response="xyz"`,
+		},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, cred.Format(tt.quirks), tt.output)
+	}
+	assert.Equal(t, cred.String(), cred.Format(Quirks{}))
+}
+
+func TestCredentialsLogValue(t *testing.T) {
+	cred := &Credentials{
+		Username: "foo",
+		Realm:    "test",
+		Nonce:    "jgdfsijdfisd",
+		URI:      "/",
+		Response: "9bbb9764c769f388f8e5ff4d26bd0449",
+		QOP:      "auth",
+		Nc:       1,
+	}
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("test", "cred", cred)
+	assert.Assert(t, strings.Contains(buf.String(), "cred.response=REDACTED"), buf.String())
+	assert.Assert(t, !strings.Contains(buf.String(), cred.Response), buf.String())
+}
+
 var credentialsResult *Credentials
 
 func BenchmarkParseCredentials(b *testing.B) {
@@ -66,3 +127,21 @@ func BenchmarkParseCredentials(b *testing.B) {
 	}
 	credentialsResult = cred // prevent optimization
 }
+
+func FuzzParseCredentials(f *testing.F) {
+	f.Fuzz(func(t *testing.T, input string) {
+		c, err := ParseCredentials(input)
+		if err != nil {
+			return
+		}
+		output := c.String()
+		c2, err := ParseCredentials(output)
+		assert.NilError(t, err, output)
+		// nc and cnonce must not be sent without a qop
+		if c.QOP == "" {
+			c.Nc = 0
+			c.Cnonce = ""
+		}
+		assert.DeepEqual(t, c2, c)
+	})
+}
@@ -1,10 +1,7 @@
 package digest
 
 import (
-	"crypto/md5"
 	"crypto/rand"
-	"crypto/sha256"
-	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
 	"hash"
@@ -46,13 +43,15 @@ type Options struct {
 	// leave these fields unset.
 	A1     string
 	Cnonce string
+
+	// Policy specifies the minimum requirements for the challenge.
+	// If nil, all supported challenges are accepted.
+	Policy *Policy
 }
 
 // CanDigest checks if the algorithm and qop are supported
 func CanDigest(c *Challenge) bool {
-	switch strings.ToUpper(c.Algorithm) {
-	case "", "MD5", "SHA-256", "SHA-512", "SHA-512-256":
-	default:
+	if _, err := newHash(c.Algorithm); err != nil {
 		return false
 	}
 	return len(c.QOP) == 0 || c.SupportsQOP("auth") || c.SupportsQOP("auth-int")
@@ -61,6 +60,9 @@ func CanDigest(c *Challenge) bool {
 // Digest creates credentials from a challenge and request options.
 // Note: if you want to re-use a challenge, you must increment the Count.
 func Digest(chal *Challenge, o Options) (*Credentials, error) {
+	if err := o.Policy.Check(chal); err != nil {
+		return nil, err
+	}
 	cred := &Credentials{
 		Username:  o.Username,
 		URI:       o.URI,
@@ -73,18 +75,9 @@ func Digest(chal *Challenge, o Options) (*Credentials, error) {
 		Userhash:  chal.Userhash,
 	}
 	// we re-use the same hash.Hash
-	var h hash.Hash
-	switch strings.ToUpper(cred.Algorithm) {
-	case "", "MD5":
-		h = md5.New()
-	case "SHA-256":
-		h = sha256.New()
-	case "SHA-512":
-		h = sha512.New()
-	case "SHA-512-256":
-		h = sha512.New512_256()
-	default:
-		return nil, fmt.Errorf("digest: unsupported algorithm: %q", cred.Algorithm)
+	h, err := newHash(cred.Algorithm)
+	if err != nil {
+		return nil, err
 	}
 	// hash the username if requested
 	if cred.Userhash {
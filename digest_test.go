@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"testing"
 
 	"gotest.tools/v3/assert"
@@ -264,3 +265,16 @@ func BenchmarkDigest(b *testing.B) {
 	}
 	digestResult = cred // prevent optimizations
 }
+
+func TestDigestNoQOP(t *testing.T) {
+	cred, err := Digest(&Challenge{Realm: "r", Nonce: "n"}, Options{
+		Method:   "GET",
+		URI:      "/",
+		Count:    1,
+		Username: "foo",
+		Password: "bar",
+	})
+	assert.NilError(t, err)
+	assert.Assert(t, !strings.Contains(cred.String(), "nc="))
+	assert.Assert(t, !strings.Contains(cred.String(), "cnonce="))
+}
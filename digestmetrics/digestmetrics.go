@@ -0,0 +1,254 @@
+// Package digestmetrics records metrics about the digest authentication
+// performed by a digest.Transport.
+//
+// The package has no dependencies. Metrics systems such as Prometheus or
+// OpenTelemetry are supported by implementing the Recorder interface.
+package digestmetrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/icholy/digest"
+)
+
+// Recorder receives authentication metrics.
+// Implementations must be safe for concurrent use.
+type Recorder interface {
+	// Challenge records a 401 response containing a usable challenge.
+	// The stale flag is true if the server reported that the previous nonce was stale.
+	Challenge(origin string, stale bool)
+
+	// Reused records a request which was sent using a cached challenge.
+	Reused(origin string)
+
+	// AuthFailure records a 401 response which could not be handled.
+	AuthFailure(origin string)
+
+	// ChallengeLatency records the duration of a round trip which was rejected with a 401.
+	ChallengeLatency(origin string, d time.Duration)
+}
+
+// Instrument configures the transport to report metrics to the recorder.
+// The hooks of an existing Trace are still invoked, and the underlying
+// Transport is wrapped.
+func Instrument(t *digest.Transport, r Recorder) {
+	t.Trace = combine(t.Trace, Trace(r))
+	t.Transport = RoundTripper(t.Transport, r)
+}
+
+// combine returns a ClientTrace which invokes the hooks of both traces.
+// If a is nil, b is returned.
+func combine(a, b *digest.ClientTrace) *digest.ClientTrace {
+	if a == nil {
+		return b
+	}
+	return &digest.ClientTrace{
+		OnChallenge: func(req *http.Request, chal *digest.Challenge) {
+			if a.OnChallenge != nil {
+				a.OnChallenge(req, chal)
+			}
+			if b.OnChallenge != nil {
+				b.OnChallenge(req, chal)
+			}
+		},
+		OnCacheHit: func(req *http.Request, chal *digest.Challenge, count int) {
+			if a.OnCacheHit != nil {
+				a.OnCacheHit(req, chal, count)
+			}
+			if b.OnCacheHit != nil {
+				b.OnCacheHit(req, chal, count)
+			}
+		},
+		OnCacheMiss: func(req *http.Request) {
+			if a.OnCacheMiss != nil {
+				a.OnCacheMiss(req)
+			}
+			if b.OnCacheMiss != nil {
+				b.OnCacheMiss(req)
+			}
+		},
+		OnRetry: func(req *http.Request, res *http.Response) {
+			if a.OnRetry != nil {
+				a.OnRetry(req, res)
+			}
+			if b.OnRetry != nil {
+				b.OnRetry(req, res)
+			}
+		},
+		OnAuthFailure: func(req *http.Request, res *http.Response, err error) {
+			if a.OnAuthFailure != nil {
+				a.OnAuthFailure(req, res, err)
+			}
+			if b.OnAuthFailure != nil {
+				b.OnAuthFailure(req, res, err)
+			}
+		},
+	}
+}
+
+// Trace returns a ClientTrace which reports challenge and failure metrics
+// to the recorder.
+func Trace(r Recorder) *digest.ClientTrace {
+	return &digest.ClientTrace{
+		OnChallenge: func(req *http.Request, chal *digest.Challenge) {
+			r.Challenge(origin(req), chal.Stale)
+		},
+		OnCacheHit: func(req *http.Request, chal *digest.Challenge, count int) {
+			r.Reused(origin(req))
+		},
+		OnAuthFailure: func(req *http.Request, res *http.Response, err error) {
+			r.AuthFailure(origin(req))
+		},
+	}
+}
+
+// RoundTripper returns an http.RoundTripper which reports the latency of
+// round trips rejected with a 401 to the recorder. It should be used as the
+// digest.Transport's underlying Transport. If next is nil, http.DefaultTransport
+// is used.
+func RoundTripper(next http.RoundTripper, r Recorder) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &roundTripper{next: next, r: r}
+}
+
+type roundTripper struct {
+	next http.RoundTripper
+	r    Recorder
+}
+
+// RoundTrip implements http.RoundTripper
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := rt.next.RoundTrip(req)
+	if err == nil && res.StatusCode == http.StatusUnauthorized {
+		rt.r.ChallengeLatency(origin(req), time.Since(start))
+	}
+	return res, err
+}
+
+// origin returns the scheme and host of the request
+func origin(req *http.Request) string {
+	return req.URL.Scheme + "://" + req.URL.Host
+}
+
+// LatencyBuckets are the upper bounds of the challenge latency histogram
+// kept by Counters. They're copied when a Counters is first used, so changes
+// don't affect Counters which have already recorded metrics.
+var LatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// Stats are the metrics recorded for an origin
+type Stats struct {
+	Challenges       int64         // challenges received
+	Stale            int64         // challenges which reported a stale nonce
+	Reused           int64         // requests sent using a cached challenge
+	AuthFailures     int64         // 401 responses which could not be handled
+	Rejected         int64         // round trips rejected with a 401
+	ChallengeLatency time.Duration // total duration of the rejected round trips
+
+	// LatencyBuckets counts the rejected round trips by duration. The count at
+	// index i is for durations up to the i-th bucket bound, and the last count is
+	// for durations longer than all of the buckets.
+	LatencyBuckets []int64
+}
+
+// Counters is a Recorder which keeps per-origin metrics in memory.
+// It implements expvar.Var so it can be published with expvar.Publish.
+type Counters struct {
+	mu      sync.Mutex
+	origins map[string]*Stats
+	bounds  []time.Duration
+}
+
+// stats returns the stats for the origin.
+// The caller must hold the lock.
+func (c *Counters) stats(origin string) *Stats {
+	if c.origins == nil {
+		c.origins = map[string]*Stats{}
+		c.bounds = slices.Clone(LatencyBuckets)
+	}
+	s, ok := c.origins[origin]
+	if !ok {
+		s = &Stats{}
+		c.origins[origin] = s
+	}
+	return s
+}
+
+// Challenge implements Recorder
+func (c *Counters) Challenge(origin string, stale bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats(origin)
+	s.Challenges++
+	if stale {
+		s.Stale++
+	}
+}
+
+// Reused implements Recorder
+func (c *Counters) Reused(origin string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats(origin).Reused++
+}
+
+// AuthFailure implements Recorder
+func (c *Counters) AuthFailure(origin string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats(origin).AuthFailures++
+}
+
+// ChallengeLatency implements Recorder
+func (c *Counters) ChallengeLatency(origin string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats(origin)
+	s.Rejected++
+	s.ChallengeLatency += d
+	if s.LatencyBuckets == nil {
+		s.LatencyBuckets = make([]int64, len(c.bounds)+1)
+	}
+	i, _ := slices.BinarySearch(c.bounds, d)
+	s.LatencyBuckets[i]++
+}
+
+// Stats returns a snapshot of the metrics for the origin
+func (c *Counters) Stats(origin string) Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.origins[origin]; ok {
+		stats := *s
+		stats.LatencyBuckets = slices.Clone(s.LatencyBuckets)
+		return stats
+	}
+	return Stats{}
+}
+
+// String returns the metrics for all origins formatted as JSON
+func (c *Counters) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := json.Marshal(c.origins)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
@@ -0,0 +1,76 @@
+package digestmetrics_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/icholy/digest"
+	"github.com/icholy/digest/digestmetrics"
+	"github.com/icholy/digest/digesttest"
+	"gotest.tools/v3/assert"
+)
+
+func TestInstrument(t *testing.T) {
+	s := digesttest.NewUnstartedServer(nil, map[string]string{"foo": "bar"})
+	s.NonceUses = 2
+	s.Start()
+	defer s.Close()
+	var counters digestmetrics.Counters
+	var retries int
+	tr := &digest.Transport{
+		Username: "foo",
+		Password: "bar",
+		Trace: &digest.ClientTrace{
+			OnRetry: func(req *http.Request, res *http.Response) { retries++ },
+		},
+	}
+	digestmetrics.Instrument(tr, &counters)
+	client := &http.Client{Transport: tr}
+	get := func() int {
+		res, err := client.Get(s.URL)
+		assert.NilError(t, err)
+		defer res.Body.Close()
+		_, _ = io.Copy(io.Discard, res.Body)
+		return res.StatusCode
+	}
+	for range 4 {
+		assert.Equal(t, get(), http.StatusOK)
+	}
+	tr.Password = "wrong"
+	assert.Equal(t, get(), http.StatusUnauthorized)
+	stats := counters.Stats(s.URL)
+	assert.Equal(t, stats.Challenges, int64(3))
+	assert.Equal(t, stats.Stale, int64(1))
+	assert.Equal(t, stats.Reused, int64(4))
+	assert.Equal(t, stats.AuthFailures, int64(1))
+	assert.Equal(t, stats.Rejected, int64(4))
+	assert.Assert(t, stats.ChallengeLatency > 0)
+	var buckets int64
+	for _, n := range stats.LatencyBuckets {
+		buckets += n
+	}
+	assert.Equal(t, buckets, stats.Rejected)
+	assert.Equal(t, retries, 3)
+	var published map[string]digestmetrics.Stats
+	assert.NilError(t, json.Unmarshal([]byte(counters.String()), &published))
+	assert.DeepEqual(t, published[s.URL], stats)
+}
+
+func TestCountersLatencyBuckets(t *testing.T) {
+	saved := slices.Clone(digestmetrics.LatencyBuckets)
+	t.Cleanup(func() { digestmetrics.LatencyBuckets = saved })
+	var counters digestmetrics.Counters
+	counters.ChallengeLatency("http://example.com", time.Millisecond)
+	digestmetrics.LatencyBuckets = append(digestmetrics.LatencyBuckets, time.Minute)
+	counters.ChallengeLatency("http://example.com", 30*time.Second)
+	counters.ChallengeLatency("http://example.org", 30*time.Second)
+	for _, origin := range []string{"http://example.com", "http://example.org"} {
+		stats := counters.Stats(origin)
+		assert.Equal(t, len(stats.LatencyBuckets), len(saved)+1)
+		assert.Equal(t, stats.LatencyBuckets[len(saved)], int64(1))
+	}
+}
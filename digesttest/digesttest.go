@@ -0,0 +1,285 @@
+// Package digesttest provides an in-process digest authentication server
+// for testing clients which use the digest package.
+package digesttest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/icholy/digest"
+)
+
+// nonce tracks the state of an issued nonce
+type nonce struct {
+	created time.Time
+	uses    int
+	nc      int
+}
+
+// Server is a digest authentication server which wraps an http.Handler.
+// The configuration fields must not be modified after the server is started.
+type Server struct {
+	*httptest.Server
+
+	// Handler is invoked for authorized requests.
+	Handler http.Handler
+
+	// Users maps usernames to passwords.
+	Users map[string]string
+
+	// Realm is the protection space advertised in challenges.
+	Realm string
+
+	// Algorithms are the advertised algorithms. A separate challenge
+	// is sent for each algorithm.
+	Algorithms []string
+
+	// QOP is the advertised quality of protection values.
+	// If empty, the legacy RFC 2069 scheme is used.
+	QOP []string
+
+	// Opaque is included in challenges and must be echoed back.
+	Opaque string
+
+	// Userhash advertises support for hashed usernames.
+	Userhash bool
+
+	// NonceLifetime is how long a nonce is valid for.
+	// If zero, nonces never expire.
+	NonceLifetime time.Duration
+
+	// NonceUses is the number of times a nonce may be used.
+	// If zero, nonces may be used any number of times.
+	NonceUses int
+
+	// NoStale omits stale=true when rejecting an expired nonce.
+	NoStale bool
+
+	// NextNonce sends a new nonce in the Authentication-Info header
+	// of authorized responses.
+	NextNonce bool
+
+	// StrictNonceCount rejects nonce counts which are not greater than
+	// the last one seen for the nonce.
+	StrictNonceCount bool
+
+	// Header is the name of the challenge header.
+	// If empty, WWW-Authenticate is used.
+	Header string
+
+	// FormatChallenge formats the challenge header value.
+	// This can be used to emulate non-compliant servers.
+	// If nil, Challenge.String is used.
+	FormatChallenge func(*digest.Challenge) string
+
+	mu         sync.Mutex
+	nonces     map[string]*nonce
+	challenges int
+	successes  int
+}
+
+// NewServer starts and returns a new Server using the default configuration.
+func NewServer(handler http.Handler, users map[string]string) *Server {
+	s := NewUnstartedServer(handler, users)
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer returns a new Server but doesn't start it.
+// The caller may change the configuration before calling Start.
+func NewUnstartedServer(handler http.Handler, users map[string]string) *Server {
+	s := &Server{
+		Handler:    handler,
+		Users:      users,
+		Realm:      "digesttest",
+		Algorithms: []string{"MD5"},
+		QOP:        []string{"auth"},
+		nonces:     map[string]*nonce{},
+	}
+	s.Server = httptest.NewUnstartedServer(s)
+	return s
+}
+
+// Challenges returns the number of challenges that have been issued.
+func (s *Server) Challenges() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.challenges
+}
+
+// Successes returns the number of requests that have been authorized.
+func (s *Server) Successes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.successes
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		s.challenge(w, false)
+		return
+	}
+	cred, err := digest.ParseCredentialsStrict(auth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// the body is only buffered when it's needed to verify the credentials
+	var body []byte
+	if cred.QOP == "auth-int" {
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	ok, stale := s.verify(r, cred, body)
+	if !ok {
+		s.challenge(w, stale)
+		return
+	}
+	if s.NextNonce {
+		w.Header().Set("Authentication-Info", fmt.Sprintf("nextnonce=%q", s.nonce()))
+	}
+	if s.Handler != nil {
+		s.Handler.ServeHTTP(w, r)
+	}
+}
+
+// challenge writes a 401 response with a challenge for each algorithm
+func (s *Server) challenge(w http.ResponseWriter, stale bool) {
+	s.mu.Lock()
+	s.challenges++
+	s.mu.Unlock()
+	n := s.nonce()
+	header := s.Header
+	if header == "" {
+		header = "WWW-Authenticate"
+	}
+	format := s.FormatChallenge
+	if format == nil {
+		format = (*digest.Challenge).String
+	}
+	for _, algorithm := range s.Algorithms {
+		w.Header().Add(header, format(&digest.Challenge{
+			Realm:     s.Realm,
+			Nonce:     n,
+			Opaque:    s.Opaque,
+			Stale:     stale && !s.NoStale,
+			Algorithm: algorithm,
+			QOP:       s.QOP,
+			Userhash:  s.Userhash,
+		}))
+	}
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// nonce issues a new nonce
+func (s *Server) nonce() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := newNonce()
+	s.nonces[n] = &nonce{created: time.Now()}
+	return n
+}
+
+// verify checks the credentials against the request. The stale return value
+// is true when the credentials were rejected only because of the nonce.
+func (s *Server) verify(r *http.Request, cred *digest.Credentials, body []byte) (ok, stale bool) {
+	if !digest.MatchURI(cred.URI, r) {
+		return false, false
+	}
+	if !slices.ContainsFunc(s.Algorithms, func(a string) bool {
+		return strings.EqualFold(a, cred.Algorithm)
+	}) {
+		return false, false
+	}
+	username, password, ok := s.lookup(cred)
+	if !ok {
+		return false, false
+	}
+	// the nonce is checked below
+	chal := &digest.Challenge{
+		Realm:     s.Realm,
+		Nonce:     cred.Nonce,
+		Opaque:    s.Opaque,
+		Algorithm: cred.Algorithm,
+		QOP:       s.QOP,
+		Userhash:  s.Userhash,
+	}
+	err := digest.Verify(chal, cred, digest.Options{
+		Method: r.Method,
+		URI:    cred.URI,
+		GetBody: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		},
+		Username: username,
+		Password: password,
+	})
+	if err != nil {
+		return false, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.nonces[cred.Nonce]
+	if !ok {
+		return false, false
+	}
+	if s.NonceLifetime > 0 && time.Since(n.created) > s.NonceLifetime {
+		delete(s.nonces, cred.Nonce)
+		return false, true
+	}
+	if s.NonceUses > 0 && n.uses >= s.NonceUses {
+		delete(s.nonces, cred.Nonce)
+		return false, true
+	}
+	if s.StrictNonceCount && cred.Nc <= n.nc {
+		return false, false
+	}
+	n.uses++
+	n.nc = max(n.nc, cred.Nc)
+	s.successes++
+	return true, false
+}
+
+// lookup finds the user which the credentials belong to
+func (s *Server) lookup(cred *digest.Credentials) (username, password string, ok bool) {
+	if !cred.Userhash {
+		password, ok = s.Users[cred.Username]
+		return cred.Username, password, ok
+	}
+	if !s.Userhash {
+		return "", "", false
+	}
+	for username, password := range s.Users {
+		hashed, err := digest.Digest(&digest.Challenge{
+			Realm:     s.Realm,
+			Algorithm: cred.Algorithm,
+			Userhash:  true,
+		}, digest.Options{Username: username})
+		if err == nil && hashed.Username == cred.Username {
+			return username, password, true
+		}
+	}
+	return "", "", false
+}
+
+func newNonce() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("digesttest: failed to generate nonce: %v", err))
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,195 @@
+package digesttest_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icholy/digest"
+	"github.com/icholy/digest/digesttest"
+	"gotest.tools/v3/assert"
+)
+
+var users = map[string]string{"foo": "bar"}
+
+func hello(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, "Hello World")
+}
+
+func get(t *testing.T, client *http.Client, url string) int {
+	t.Helper()
+	res, err := client.Get(url)
+	assert.NilError(t, err)
+	defer res.Body.Close()
+	_, err = io.Copy(io.Discard, res.Body)
+	assert.NilError(t, err)
+	return res.StatusCode
+}
+
+func TestServer(t *testing.T) {
+	tests := []struct {
+		name       string
+		configure  func(*digesttest.Server)
+		requests   int
+		challenges int
+	}{
+		{
+			name:       "Default",
+			configure:  func(s *digesttest.Server) {},
+			requests:   3,
+			challenges: 1,
+		},
+		{
+			name: "SHA-256",
+			configure: func(s *digesttest.Server) {
+				s.Algorithms = []string{"SHA-256", "MD5"}
+			},
+			requests:   3,
+			challenges: 1,
+		},
+		{
+			name: "Userhash",
+			configure: func(s *digesttest.Server) {
+				s.Algorithms = []string{"SHA-512-256"}
+				s.Userhash = true
+				s.Opaque = "opaque"
+			},
+			requests:   3,
+			challenges: 1,
+		},
+		{
+			name: "RFC2069",
+			configure: func(s *digesttest.Server) {
+				s.Algorithms = []string{""}
+				s.QOP = nil
+			},
+			requests:   3,
+			challenges: 1,
+		},
+		{
+			name: "AuthInt",
+			configure: func(s *digesttest.Server) {
+				s.QOP = []string{"auth-int"}
+			},
+			requests:   3,
+			challenges: 1,
+		},
+		{
+			name: "OneShotNonce",
+			configure: func(s *digesttest.Server) {
+				s.NonceUses = 1
+			},
+			requests:   3,
+			challenges: 3,
+		},
+		{
+			name: "StrictNonceCount",
+			configure: func(s *digesttest.Server) {
+				s.StrictNonceCount = true
+			},
+			requests:   3,
+			challenges: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := digesttest.NewUnstartedServer(http.HandlerFunc(hello), users)
+			tt.configure(s)
+			s.Start()
+			defer s.Close()
+			client := &http.Client{
+				Transport: &digest.Transport{
+					Username: "foo",
+					Password: "bar",
+				},
+			}
+			for range tt.requests {
+				assert.Equal(t, get(t, client, s.URL), http.StatusOK)
+			}
+			assert.Equal(t, s.Challenges(), tt.challenges)
+			assert.Equal(t, s.Successes(), tt.requests)
+		})
+	}
+}
+
+func TestServerStale(t *testing.T) {
+	s := digesttest.NewUnstartedServer(http.HandlerFunc(hello), users)
+	s.NonceUses = 1
+	s.Start()
+	defer s.Close()
+	var stale []bool
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username: "foo",
+			Password: "bar",
+			FindChallenge: func(h http.Header) (*digest.Challenge, error) {
+				chal, err := digest.FindChallenge(h)
+				if err == nil {
+					stale = append(stale, chal.Stale)
+				}
+				return chal, err
+			},
+		},
+	}
+	assert.Equal(t, get(t, client, s.URL), http.StatusOK)
+	assert.Equal(t, get(t, client, s.URL), http.StatusOK)
+	assert.DeepEqual(t, stale, []bool{false, true})
+}
+
+func TestServerNonceLifetime(t *testing.T) {
+	s := digesttest.NewUnstartedServer(http.HandlerFunc(hello), users)
+	s.NonceLifetime = 100 * time.Millisecond
+	s.Start()
+	defer s.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username: "foo",
+			Password: "bar",
+		},
+	}
+	assert.Equal(t, get(t, client, s.URL), http.StatusOK)
+	assert.Equal(t, get(t, client, s.URL), http.StatusOK)
+	assert.Equal(t, s.Challenges(), 1)
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, get(t, client, s.URL), http.StatusOK)
+	assert.Equal(t, s.Challenges(), 2)
+}
+
+func TestServerUnauthorized(t *testing.T) {
+	s := digesttest.NewServer(http.HandlerFunc(hello), users)
+	defer s.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username: "foo",
+			Password: "wrong",
+		},
+	}
+	assert.Equal(t, get(t, client, s.URL), http.StatusUnauthorized)
+	assert.Equal(t, s.Successes(), 0)
+}
+
+func TestServerQuirks(t *testing.T) {
+	s := digesttest.NewUnstartedServer(http.HandlerFunc(hello), users)
+	s.Header = "X-Authenticate"
+	s.FormatChallenge = func(c *digest.Challenge) string {
+		return strings.Replace(c.String(), "Digest ", "DIGEST ", 1)
+	}
+	s.Start()
+	defer s.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username: "foo",
+			Password: "bar",
+			FindChallenge: func(h http.Header) (*digest.Challenge, error) {
+				value := h.Get("X-Authenticate")
+				if value == "" {
+					return nil, digest.ErrNoChallenge
+				}
+				return digest.ParseChallenge(value)
+			},
+		},
+	}
+	assert.Equal(t, get(t, client, s.URL), http.StatusOK)
+}
@@ -0,0 +1,18 @@
+package digest
+
+import (
+	"maps"
+	"testing"
+)
+
+// RestoreAlgorithms restores the algorithm registry when the test completes
+func RestoreAlgorithms(t testing.TB) {
+	algorithmsMu.Lock()
+	saved := maps.Clone(algorithms)
+	algorithmsMu.Unlock()
+	t.Cleanup(func() {
+		algorithmsMu.Lock()
+		algorithms = saved
+		algorithmsMu.Unlock()
+	})
+}
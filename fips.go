@@ -0,0 +1,10 @@
+//go:build go1.24
+
+package digest
+
+import "crypto/fips140"
+
+// fipsEnabled returns true if Go's FIPS 140-3 mode is enabled
+func fipsEnabled() bool {
+	return fips140.Enabled()
+}
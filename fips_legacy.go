@@ -0,0 +1,8 @@
+//go:build !go1.24
+
+package digest
+
+// fipsEnabled returns false because crypto/fips140 requires go1.24
+func fipsEnabled() bool {
+	return false
+}
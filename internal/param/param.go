@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"strconv"
 	"strings"
 )
 
@@ -15,14 +14,44 @@ type Param struct {
 	Quote bool
 }
 
-// String returns the formatted parameter
+// String returns the formatted parameter.
+// Values which are not valid tokens are quoted even if Quote is false.
 func (p Param) String() string {
-	if p.Quote {
-		return p.Key + "=" + strconv.Quote(p.Value)
+	if p.Quote || !isToken(p.Value) {
+		return p.Key + "=" + quote(p.Value)
 	}
 	return p.Key + "=" + p.Value
 }
 
+// quote formats s as a quoted-string. Unlike strconv.Quote, only the '"'
+// and '\' characters are escaped so the output round-trips through parseString.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		if r == '"' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// isToken returns true if s can be parsed by parseIdent
+func isToken(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isIdentByte(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentByte(b byte) bool {
+	return ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || '0' <= b && b <= '9' || b == '-'
+}
+
 // Format formats the parameters to be included in the header
 func Format(pp ...Param) string {
 	var b strings.Builder
@@ -35,6 +64,32 @@ func Format(pp ...Param) string {
 	return b.String()
 }
 
+// Duplicate returns the first parameter key which appears more than once.
+// Keys are compared case-insensitively.
+func Duplicate(pp []Param) (string, bool) {
+	seen := map[string]bool{}
+	for _, p := range pp {
+		key := strings.ToLower(p.Key)
+		if seen[key] {
+			return p.Key, true
+		}
+		seen[key] = true
+	}
+	return "", false
+}
+
+// List splits a comma separated list value and trims the whitespace
+// around each element. Empty elements are omitted.
+func List(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
 // Parse parses the header parameters
 func Parse(s string) ([]Param, error) {
 	var pp []Param
@@ -68,7 +123,7 @@ func parseIdent(br *bufio.Reader) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		if !(('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || '0' <= b && b <= '9' || b == '-') {
+		if !isIdentByte(b) {
 			if err := br.UnreadByte(); err != nil {
 				return "", err
 			}
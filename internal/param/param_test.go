@@ -118,3 +118,16 @@ func TestParam(t *testing.T) {
 		})
 	}
 }
+
+func FuzzParse(f *testing.F) {
+	f.Fuzz(func(t *testing.T, input string) {
+		params, err := Parse(input)
+		if err != nil {
+			return
+		}
+		output := Format(params...)
+		params2, err := Parse(output)
+		assert.NilError(t, err, output)
+		assert.DeepEqual(t, params2, params)
+	})
+}
@@ -0,0 +1,59 @@
+package digest
+
+import (
+	"crypto/md5"
+	"fmt"
+	"sync/atomic"
+)
+
+// DisabledAlgorithmError is returned when a challenge
+// uses an algorithm which has been disabled.
+type DisabledAlgorithmError struct {
+	Algorithm string
+}
+
+// Error implements error
+func (e *DisabledAlgorithmError) Error() string {
+	algorithm := e.Algorithm
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+	return fmt.Sprintf("digest: algorithm %q is disabled", algorithm)
+}
+
+var md5Disabled atomic.Bool
+
+// DisableMD5 controls whether MD5 and MD5-sess challenges are refused by
+// every function in the package. Challenges without an algorithm use MD5
+// and are also refused.
+func DisableMD5(disable bool) {
+	md5Disabled.Store(disable)
+}
+
+// MD5Disabled returns true if MD5 is disabled. This is the case if
+// DisableMD5 was called or Go's FIPS 140-3 mode is enabled.
+func MD5Disabled() bool {
+	return md5Disabled.Load() || fipsEnabled()
+}
+
+// newMD5 is the only way MD5 hashes are created
+var newMD5 = md5.New
+
+// isMD5 returns true if the algorithm uses MD5
+func isMD5(algorithm string) bool {
+	switch canonical(algorithm) {
+	case "MD5", "MD5-SESS":
+		return true
+	default:
+		return false
+	}
+}
+
+// checkMD5 returns a DisabledAlgorithmError if the
+// algorithm uses MD5 and MD5 is disabled
+func checkMD5(algorithm string) error {
+	if isMD5(algorithm) && MD5Disabled() {
+		return &DisabledAlgorithmError{Algorithm: algorithm}
+	}
+	return nil
+}
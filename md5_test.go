@@ -0,0 +1,57 @@
+package digest
+
+import (
+	"crypto/md5"
+	"errors"
+	"hash"
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDisableMD5(t *testing.T) {
+	DisableMD5(true)
+	defer DisableMD5(false)
+	// fail the test if any code path creates an MD5 hash
+	newMD5 = func() hash.Hash {
+		panic("MD5 hash created")
+	}
+	defer func() { newMD5 = md5.New }()
+	assert.Assert(t, MD5Disabled())
+	for _, algorithm := range []string{"", "MD5", "md5", "MD5-sess"} {
+		t.Run(algorithm, func(t *testing.T) {
+			chal := &Challenge{
+				Realm:     "test",
+				Nonce:     "abc",
+				Algorithm: algorithm,
+				QOP:       []string{"auth"},
+			}
+			assert.Assert(t, !CanDigest(chal))
+			_, err := Digest(chal, Options{Username: "foo", Password: "bar"})
+			var disabled *DisabledAlgorithmError
+			assert.Assert(t, errors.As(err, &disabled))
+			assert.Equal(t, disabled.Algorithm, algorithm)
+			h := http.Header{}
+			h.Add("WWW-Authenticate", chal.String())
+			_, err = FindChallenge(h)
+			assert.Assert(t, errors.As(err, &disabled))
+		})
+	}
+	// other algorithms still work
+	_, err := Digest(&Challenge{Algorithm: "SHA-256"}, Options{})
+	assert.NilError(t, err)
+}
+
+func TestPolicyDisableMD5(t *testing.T) {
+	p := &Policy{DisableMD5: true}
+	h := http.Header{}
+	h.Add("WWW-Authenticate", `Digest realm="test", nonce="a", qop="auth"`)
+	h.Add("WWW-Authenticate", `Digest realm="test", nonce="b", algorithm=SHA-256, qop="auth"`)
+	chal, err := p.FindChallenge(h)
+	assert.NilError(t, err)
+	assert.Equal(t, chal.Algorithm, "SHA-256")
+	var disabled *DisabledAlgorithmError
+	err = p.Check(&Challenge{Algorithm: "MD5"})
+	assert.Assert(t, errors.As(err, &disabled))
+}
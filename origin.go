@@ -0,0 +1,67 @@
+package digest
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ErrUntrustedOrigin is reported when a challenge is not answered
+// because the Transport's OriginPolicy doesn't allow the origin.
+var ErrUntrustedOrigin = errors.New("digest: untrusted origin")
+
+// OriginPolicy controls which origins a Transport sends credentials to.
+// Credentials are always sent to the origin of the request which started the
+// redirect chain, and to the same host over https if that request used http.
+type OriginPolicy struct {
+	// Hosts is a list of hostname patterns which credentials may be sent to
+	// in addition to the origin of the request which started the redirect chain.
+	// Patterns use the path.Match syntax, so "*.example.com" matches every
+	// subdomain of example.com and "*" matches every host.
+	Hosts []string
+
+	// HTTPSOnly prevents credentials from being sent over http.
+	HTTPSOnly bool
+}
+
+// allows returns true if credentials may be sent with the request
+func (p *OriginPolicy) allows(req *http.Request) bool {
+	if p == nil {
+		p = &OriginPolicy{}
+	}
+	if p.HTTPSOnly && req.URL.Scheme != "https" {
+		return false
+	}
+	if u := initial(req).URL; sameOrigin(req.URL, u) || upgraded(req.URL, u) {
+		return true
+	}
+	host := strings.ToLower(req.URL.Hostname())
+	for _, pattern := range p.Hosts {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// initial returns the request which started the redirect chain
+func initial(req *http.Request) *http.Request {
+	for req.Response != nil && req.Response.Request != nil {
+		req = req.Response.Request
+	}
+	return req
+}
+
+// upgraded returns true if a is the https version of the http url b.
+// The port is ignored because it changes with the scheme.
+func upgraded(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, "https") && strings.EqualFold(b.Scheme, "http") &&
+		strings.EqualFold(a.Hostname(), b.Hostname())
+}
+
+// sameOrigin returns true if the urls have the same scheme, host, and port
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
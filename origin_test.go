@@ -0,0 +1,98 @@
+package digest_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/icholy/digest"
+	"github.com/icholy/digest/digesttest"
+	"gotest.tools/v3/assert"
+)
+
+func TestTransportOrigins(t *testing.T) {
+	tests := []struct {
+		name       string
+		origins    *digest.OriginPolicy
+		status     int
+		err        error
+		redirected int
+		target     int
+	}{
+		{
+			name:       "same origin",
+			status:     http.StatusUnauthorized,
+			err:        digest.ErrUntrustedOrigin,
+			redirected: 1,
+		},
+		{
+			name:       "host pattern",
+			origins:    &digest.OriginPolicy{Hosts: []string{"127.0.0.*"}},
+			status:     http.StatusOK,
+			redirected: 1,
+			target:     1,
+		},
+		{
+			name:       "host mismatch",
+			origins:    &digest.OriginPolicy{Hosts: []string{"*.example.com"}},
+			status:     http.StatusUnauthorized,
+			err:        digest.ErrUntrustedOrigin,
+			redirected: 1,
+		},
+		{
+			name:    "https only",
+			origins: &digest.OriginPolicy{Hosts: []string{"*"}, HTTPSOnly: true},
+			status:  http.StatusUnauthorized,
+			err:     digest.ErrUntrustedOrigin,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := digesttest.NewServer(nil, map[string]string{"foo": "bar"})
+			defer target.Close()
+			redirect := digesttest.NewServer(http.RedirectHandler(target.URL, http.StatusFound), map[string]string{"foo": "bar"})
+			defer redirect.Close()
+			var failure error
+			client := &http.Client{
+				Transport: &digest.Transport{
+					Username: "foo",
+					Password: "bar",
+					Origins:  tt.origins,
+					Trace: &digest.ClientTrace{
+						OnAuthFailure: func(req *http.Request, res *http.Response, err error) {
+							failure = err
+						},
+					},
+				},
+			}
+			res, err := client.Get(redirect.URL)
+			assert.NilError(t, err)
+			res.Body.Close()
+			assert.Equal(t, res.StatusCode, tt.status)
+			assert.Assert(t, errors.Is(failure, tt.err))
+			assert.Equal(t, redirect.Successes(), tt.redirected)
+			assert.Equal(t, target.Successes(), tt.target)
+		})
+	}
+}
+
+func TestTransportOriginsUpgrade(t *testing.T) {
+	target := digesttest.NewUnstartedServer(nil, map[string]string{"foo": "bar"})
+	target.StartTLS()
+	defer target.Close()
+	redirect := digesttest.NewServer(http.RedirectHandler(target.URL, http.StatusFound), map[string]string{"foo": "bar"})
+	defer redirect.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username:  "foo",
+			Password:  "bar",
+			Transport: target.Client().Transport,
+		},
+	}
+	res, err := client.Get(redirect.URL)
+	assert.NilError(t, err)
+	res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, redirect.Successes(), 1)
+	assert.Equal(t, target.Successes(), 1)
+}
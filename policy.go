@@ -0,0 +1,126 @@
+package digest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrWeakChallenge is returned when a challenge doesn't satisfy the Policy
+var ErrWeakChallenge = errors.New("digest: challenge does not satisfy policy")
+
+// Policy specifies the minimum requirements for a challenge.
+// It protects against a man-in-the-middle removing the stronger
+// challenges from a response.
+type Policy struct {
+	// MinAlgorithm is the weakest algorithm which is accepted.
+	// Algorithms are ranked by the size of their hash, so SHA-256 and
+	// SHA-512-256 are equivalent. If empty, all algorithms are accepted.
+	MinAlgorithm string
+
+	// RequireQOP rejects challenges without a qop. These challenges
+	// use the legacy RFC 2069 response calculation.
+	RequireQOP bool
+
+	// RequireUserhash rejects challenges which don't support userhash.
+	RequireUserhash bool
+
+	// DisableMD5 rejects MD5 and MD5-sess challenges with a DisabledAlgorithmError.
+	// Use the DisableMD5 function to disable MD5 for the whole package.
+	DisableMD5 bool
+}
+
+// Check returns an error wrapping ErrWeakChallenge or a DisabledAlgorithmError
+// if the challenge doesn't satisfy the policy. A nil policy accepts every challenge.
+func (p *Policy) Check(c *Challenge) error {
+	return p.check(c, 0)
+}
+
+// FindChallenge returns the first supported challenge in the headers which
+// satisfies the policy.
+func (p *Policy) FindChallenge(h http.Header) (*Challenge, error) {
+	return findChallenge(h, p.Check)
+}
+
+// check is like Check but also rejects algorithms weaker than the
+// provided strength
+func (p *Policy) check(c *Challenge, min int) error {
+	if p != nil && p.DisableMD5 && isMD5(c.Algorithm) {
+		return &DisabledAlgorithmError{Algorithm: c.Algorithm}
+	}
+	if p != nil && p.MinAlgorithm != "" {
+		s := strength(p.MinAlgorithm)
+		if s == 0 {
+			return fmt.Errorf("digest: unsupported minimum algorithm: %q", p.MinAlgorithm)
+		}
+		min = max(min, s)
+	}
+	if strength(c.Algorithm) < min {
+		return fmt.Errorf("%w: algorithm %q is too weak", ErrWeakChallenge, c.Algorithm)
+	}
+	if p == nil {
+		return nil
+	}
+	if p.RequireQOP && len(c.QOP) == 0 {
+		return fmt.Errorf("%w: qop is required", ErrWeakChallenge)
+	}
+	if p.RequireUserhash && !c.Userhash {
+		return fmt.Errorf("%w: userhash is required", ErrWeakChallenge)
+	}
+	return nil
+}
+
+// selectChallenge finds a challenge which satisfies the policy and is at
+// least as strong as min. If find is nil, the first satisfying challenge is used.
+func selectChallenge(h http.Header, find func(http.Header) (*Challenge, error), p *Policy, min int) (*Challenge, error) {
+	check := func(c *Challenge) error {
+		return p.check(c, min)
+	}
+	if find == nil {
+		return findChallenge(h, check)
+	}
+	chal, err := find(h)
+	if err != nil {
+		return nil, err
+	}
+	if err := check(chal); err != nil {
+		return nil, err
+	}
+	return chal, nil
+}
+
+// pin records the strongest algorithm offered in the response and returns
+// the strongest algorithm the origin has offered. Zero is returned if
+// PinAlgorithm is disabled or the origin has never offered a digest challenge.
+func (t *Transport) pin(res *http.Response) int {
+	if !t.PinAlgorithm {
+		return 0
+	}
+	var offered int
+	for _, header := range res.Header.Values("WWW-Authenticate") {
+		if !IsDigest(header) {
+			continue
+		}
+		if chal, err := ParseChallenge(header); err == nil && CanDigest(chal) {
+			offered = max(offered, strength(chal.Algorithm))
+		}
+	}
+	origin := res.Request.URL.Scheme + "://" + res.Request.URL.Host
+	t.pinsMu.Lock()
+	defer t.pinsMu.Unlock()
+	if t.pins == nil {
+		t.pins = map[string]int{}
+	}
+	t.pins[origin] = max(t.pins[origin], offered)
+	return t.pins[origin]
+}
+
+// strength returns the size of the algorithm's hash.
+// Zero is returned for unsupported algorithms.
+func strength(algorithm string) int {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return 0
+	}
+	return h.Size()
+}
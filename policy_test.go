@@ -0,0 +1,160 @@
+package digest_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/icholy/digest"
+	"github.com/icholy/digest/digesttest"
+	"gotest.tools/v3/assert"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *digest.Policy
+		chal   *digest.Challenge
+		weak   bool
+	}{
+		{
+			name: "nil policy",
+			chal: &digest.Challenge{},
+		},
+		{
+			name:   "min algorithm",
+			policy: &digest.Policy{MinAlgorithm: "SHA-256"},
+			chal:   &digest.Challenge{Algorithm: "SHA-512-256", QOP: []string{"auth"}},
+		},
+		{
+			name:   "weak algorithm",
+			policy: &digest.Policy{MinAlgorithm: "SHA-256"},
+			chal:   &digest.Challenge{Algorithm: "MD5", QOP: []string{"auth"}},
+			weak:   true,
+		},
+		{
+			name:   "default algorithm",
+			policy: &digest.Policy{MinAlgorithm: "SHA-256"},
+			chal:   &digest.Challenge{QOP: []string{"auth"}},
+			weak:   true,
+		},
+		{
+			name:   "missing qop",
+			policy: &digest.Policy{RequireQOP: true},
+			chal:   &digest.Challenge{Algorithm: "SHA-256"},
+			weak:   true,
+		},
+		{
+			name:   "missing userhash",
+			policy: &digest.Policy{RequireUserhash: true},
+			chal:   &digest.Challenge{Algorithm: "SHA-256", QOP: []string{"auth"}},
+			weak:   true,
+		},
+		{
+			name:   "userhash",
+			policy: &digest.Policy{RequireUserhash: true},
+			chal:   &digest.Challenge{Algorithm: "SHA-256", Userhash: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.chal)
+			assert.Equal(t, errors.Is(err, digest.ErrWeakChallenge), tt.weak)
+			_, err = digest.Digest(tt.chal, digest.Options{Policy: tt.policy})
+			assert.Equal(t, errors.Is(err, digest.ErrWeakChallenge), tt.weak)
+		})
+	}
+}
+
+func TestPolicyFindChallenge(t *testing.T) {
+	h := http.Header{}
+	h.Add("WWW-Authenticate", `Digest realm="test", nonce="a", algorithm=MD5, qop="auth"`)
+	h.Add("WWW-Authenticate", `Digest realm="test", nonce="b", algorithm=SHA-256, qop="auth"`)
+	p := &digest.Policy{MinAlgorithm: "SHA-256"}
+	chal, err := p.FindChallenge(h)
+	assert.NilError(t, err)
+	assert.Equal(t, chal.Algorithm, "SHA-256")
+	h.Del("WWW-Authenticate")
+	h.Add("WWW-Authenticate", `Digest realm="test", nonce="a", algorithm=MD5, qop="auth"`)
+	_, err = p.FindChallenge(h)
+	assert.Assert(t, errors.Is(err, digest.ErrWeakChallenge))
+}
+
+func TestTransportPolicy(t *testing.T) {
+	s := digesttest.NewUnstartedServer(nil, map[string]string{"foo": "bar"})
+	s.Algorithms = []string{"MD5", "SHA-256"}
+	s.Start()
+	defer s.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username: "foo",
+			Password: "bar",
+			Policy:   &digest.Policy{MinAlgorithm: "SHA-256", RequireQOP: true},
+		},
+	}
+	getOK(t, client, s.URL)
+}
+
+func TestTransportPinAlgorithm(t *testing.T) {
+	var mu sync.Mutex
+	algorithms := []string{"MD5", "SHA-256"}
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if strings.Contains(r.Header.Get("Authorization"), "algorithm=MD5") {
+			io.WriteString(w, "Hello World")
+			return
+		}
+		for _, algorithm := range algorithms {
+			w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Digest realm="test", nonce="%d", algorithm=%s, qop="auth"`, requests, algorithm))
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username:     "foo",
+			Password:     "bar",
+			PinAlgorithm: true,
+		},
+	}
+	// the strongest offered algorithm is used even though the
+	// server prefers MD5, so the credentials are rejected
+	res, err := client.Get(ts.URL)
+	assert.NilError(t, err)
+	res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+	// the server is downgraded to MD5
+	mu.Lock()
+	algorithms = []string{"MD5"}
+	requests = 0
+	mu.Unlock()
+	_, err = client.Get(ts.URL)
+	assert.Assert(t, errors.Is(err, digest.ErrWeakChallenge))
+	mu.Lock()
+	assert.Equal(t, requests, 1)
+	mu.Unlock()
+}
+
+func TestTransportDisableMD5(t *testing.T) {
+	s := digesttest.NewServer(nil, map[string]string{"foo": "bar"})
+	defer s.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username: "foo",
+			Password: "bar",
+			Policy:   &digest.Policy{DisableMD5: true},
+		},
+	}
+	_, err := client.Get(s.URL)
+	var disabled *digest.DisabledAlgorithmError
+	assert.Assert(t, errors.As(err, &disabled))
+	assert.Equal(t, s.Successes(), 0)
+}
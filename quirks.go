@@ -0,0 +1,76 @@
+package digest
+
+import "net/http"
+
+// Quirks adjust the credentials for servers which don't comply with the RFCs.
+// The response parameter is always last, the nc parameter is never quoted, and
+// the algorithm is echoed exactly as it appeared in the challenge.
+// Named profiles for documented failures are listed in Profiles.
+type Quirks struct {
+	// QuoteAlgorithm quotes the algorithm parameter
+	QuoteAlgorithm bool
+
+	// QuoteQOP quotes the qop parameter
+	QuoteQOP bool
+
+	// AbsoluteURI uses the absolute request URL, including the scheme
+	// and host, as the uri parameter instead of the request URI.
+	AbsoluteURI bool
+}
+
+var (
+	// QuirksQuoted quotes the algorithm and qop parameters.
+	QuirksQuoted = Quirks{QuoteAlgorithm: true, QuoteQOP: true}
+
+	// QuirksAbsoluteURI uses the absolute request URL as the uri parameter.
+	QuirksAbsoluteURI = Quirks{AbsoluteURI: true}
+)
+
+// Profile is a named set of quirks for servers with a documented failure
+type Profile struct {
+	// Name identifies the profile in configuration
+	Name string
+
+	// Failure describes how the affected servers reject compliant credentials
+	Failure string
+
+	// Quirks are the adjustments which fix the failure
+	Quirks Quirks
+}
+
+// Profiles are the built-in quirk profiles
+var Profiles = []Profile{
+	{
+		Name:    "response-last",
+		Failure: "the response parameter is ignored unless it's last (https://github.com/icholy/digest/issues/8)",
+		Quirks:  Quirks{},
+	},
+	{
+		Name:    "quoted",
+		Failure: "unquoted algorithm and qop parameters are rejected",
+		Quirks:  QuirksQuoted,
+	},
+	{
+		Name:    "absolute-uri",
+		Failure: "the uri parameter is compared to the absolute request URL",
+		Quirks:  QuirksAbsoluteURI,
+	},
+}
+
+// LookupProfile returns the built-in profile with the name
+func LookupProfile(name string) (Profile, bool) {
+	for _, p := range Profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Profile{}, false
+}
+
+// uri returns the uri parameter for the request
+func (q Quirks) uri(req *http.Request) string {
+	if q.AbsoluteURI {
+		return req.URL.Scheme + "://" + req.URL.Host + req.URL.RequestURI()
+	}
+	return req.URL.RequestURI()
+}
@@ -0,0 +1,60 @@
+package digest_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/icholy/digest"
+	"github.com/icholy/digest/digesttest"
+	"gotest.tools/v3/assert"
+)
+
+func TestTransportQuirks(t *testing.T) {
+	s := digesttest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cred, err := digest.ParseCredentials(r.Header.Get("Authorization"))
+		if !assert.Check(t, err) {
+			return
+		}
+		assert.Check(t, cred.URI == "http://"+r.Host+"/path?query=1", cred.URI)
+		assert.Check(t, strings.Contains(r.Header.Get("Authorization"), `qop="auth"`))
+	}), map[string]string{"foo": "bar"})
+	defer s.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username: "foo",
+			Password: "bar",
+			Quirks: digest.Quirks{
+				QuoteQOP:    true,
+				AbsoluteURI: true,
+			},
+		},
+	}
+	getOK(t, client, s.URL+"/path?query=1")
+}
+
+func TestLookupProfile(t *testing.T) {
+	cred := &digest.Credentials{
+		Username:  "foo",
+		Realm:     "test",
+		Nonce:     "abc",
+		URI:       "/",
+		Response:  "xyz",
+		Algorithm: "MD5",
+		Cnonce:    "123",
+		QOP:       "auth",
+		Nc:        1,
+		Userhash:  true,
+	}
+	for _, p := range digest.Profiles {
+		t.Run(p.Name, func(t *testing.T) {
+			found, ok := digest.LookupProfile(p.Name)
+			assert.Assert(t, ok)
+			assert.DeepEqual(t, found, p)
+			assert.Assert(t, p.Failure != "")
+			assert.Assert(t, strings.HasSuffix(cred.Format(p.Quirks), `, response="xyz"`))
+		})
+	}
+	_, ok := digest.LookupProfile("unknown")
+	assert.Assert(t, !ok)
+}
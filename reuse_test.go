@@ -0,0 +1,86 @@
+package digest_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/icholy/digest"
+	"github.com/icholy/digest/digesttest"
+	"gotest.tools/v3/assert"
+)
+
+// reuseStats counts cache events reported by a ClientTrace
+type reuseStats struct {
+	hits, misses, stale int
+}
+
+func (rs *reuseStats) trace() *digest.ClientTrace {
+	return &digest.ClientTrace{
+		OnCacheHit: func(req *http.Request, chal *digest.Challenge, count int) {
+			rs.hits++
+		},
+		OnCacheMiss: func(req *http.Request) {
+			rs.misses++
+		},
+		OnChallenge: func(req *http.Request, chal *digest.Challenge) {
+			if chal.Stale {
+				rs.stale++
+			}
+		},
+	}
+}
+
+func getOK(t *testing.T, client *http.Client, url string) {
+	t.Helper()
+	res, err := client.Get(url)
+	assert.NilError(t, err)
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+}
+
+func TestTransportMaxNonceUses(t *testing.T) {
+	s := digesttest.NewUnstartedServer(nil, map[string]string{"foo": "bar"})
+	s.NonceUses = 3
+	s.Start()
+	defer s.Close()
+	var stats reuseStats
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username:     "foo",
+			Password:     "bar",
+			MaxNonceUses: 3,
+			Trace:        stats.trace(),
+		},
+	}
+	for range 9 {
+		getOK(t, client, s.URL)
+	}
+	assert.Equal(t, s.Challenges(), 3)
+	assert.Equal(t, s.Successes(), 9)
+	assert.Equal(t, stats, reuseStats{hits: 6, misses: 3})
+}
+
+func TestTransportMaxNonceAge(t *testing.T) {
+	s := digesttest.NewUnstartedServer(nil, map[string]string{"foo": "bar"})
+	s.NonceLifetime = 100 * time.Millisecond
+	s.Start()
+	defer s.Close()
+	var stats reuseStats
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username:    "foo",
+			Password:    "bar",
+			MaxNonceAge: 50 * time.Millisecond,
+			Trace:       stats.trace(),
+		},
+	}
+	getOK(t, client, s.URL)
+	getOK(t, client, s.URL)
+	time.Sleep(200 * time.Millisecond)
+	getOK(t, client, s.URL)
+	assert.Equal(t, s.Challenges(), 2)
+	assert.Equal(t, stats, reuseStats{hits: 1, misses: 2})
+}
@@ -0,0 +1,154 @@
+package digest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// rfcVectors are the worked examples from the RFCs
+var rfcVectors = []struct {
+	name          string
+	method        string
+	username      string
+	password      string
+	challenge     string
+	authorization string
+}{
+	{
+		// https://www.rfc-editor.org/rfc/rfc2617#section-3.5
+		name:     "RFC2617",
+		method:   "GET",
+		username: "Mufasa",
+		password: "Circle Of Life",
+		challenge: `Digest realm="testrealm@host.com", qop="auth,auth-int", ` +
+			`nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", ` +
+			`opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+		authorization: `Digest username="Mufasa", realm="testrealm@host.com", ` +
+			`nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", uri="/dir/index.html", ` +
+			`qop=auth, nc=00000001, cnonce="0a4f113b", ` +
+			`response="6629fae49393a05397450978507c4ef1", ` +
+			`opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+	},
+	{
+		// https://www.rfc-editor.org/rfc/rfc7616#section-3.9.1
+		name:     "RFC7616-MD5",
+		method:   "GET",
+		username: "Mufasa",
+		password: "Circle of Life",
+		challenge: `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=MD5, ` +
+			`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", ` +
+			`opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+		authorization: `Digest username="Mufasa", realm="http-auth@example.org", ` +
+			`uri="/dir/index.html", algorithm=MD5, ` +
+			`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", nc=00000001, ` +
+			`cnonce="f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", qop=auth, ` +
+			`response="8ca523f5e9506fed4657c9700eebdbec", ` +
+			`opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+	},
+	{
+		// https://www.rfc-editor.org/rfc/rfc7616#section-3.9.1
+		name:     "RFC7616-SHA-256",
+		method:   "GET",
+		username: "Mufasa",
+		password: "Circle of Life",
+		challenge: `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, ` +
+			`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", ` +
+			`opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+		authorization: `Digest username="Mufasa", realm="http-auth@example.org", ` +
+			`uri="/dir/index.html", algorithm=SHA-256, ` +
+			`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", nc=00000001, ` +
+			`cnonce="f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", qop=auth, ` +
+			`response="753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1", ` +
+			`opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+	},
+	{
+		// https://www.rfc-editor.org/rfc/rfc7616#section-3.9.2
+		// The username hash and response in the RFC are incorrect, these are the
+		// corrected values from https://www.rfc-editor.org/errata/eid4897
+		name:     "RFC7616-SHA-512-256-userhash",
+		method:   "GET",
+		username: "Jäsøn Doe",
+		password: "Secret, or not?",
+		challenge: `Digest realm="api@example.org", qop="auth", algorithm=SHA-512-256, ` +
+			`nonce="5TsQWLVdgBdmrQ0XsxbDODV+57QdFR34I9HAbC/RVvkK", ` +
+			`opaque="HRPCssKJSGjCrkzDg8OhwpzCiGPChXYjwrI2QmXDnsOS", ` +
+			`charset=UTF-8, userhash=true`,
+		authorization: `Digest username="793263caabb707a56211940d90411ea4a575adeccb7e360aeb624ed06ece9b0b", ` +
+			`realm="api@example.org", uri="/doe.json", algorithm=SHA-512-256, ` +
+			`nonce="5TsQWLVdgBdmrQ0XsxbDODV+57QdFR34I9HAbC/RVvkK", nc=00000001, ` +
+			`cnonce="NTg6RKcb9boFIAS3KrFK9BGeh+iDa/sm6jUMp2wds69v", qop=auth, ` +
+			`response="3798d4131c277846293534c3edc11bd8a5e4cdcbff78b05db9d95eeb1cec68a5", ` +
+			`opaque="HRPCssKJSGjCrkzDg8OhwpzCiGPChXYjwrI2QmXDnsOS", userhash=true`,
+	},
+}
+
+func TestRFCDigest(t *testing.T) {
+	for _, tt := range rfcVectors {
+		t.Run(tt.name, func(t *testing.T) {
+			chal, err := ParseChallenge(tt.challenge)
+			assert.NilError(t, err)
+			want, err := ParseCredentials(tt.authorization)
+			assert.NilError(t, err)
+			cred, err := Digest(chal, Options{
+				Method:   tt.method,
+				URI:      want.URI,
+				Count:    want.Nc,
+				Cnonce:   want.Cnonce,
+				Username: tt.username,
+				Password: tt.password,
+			})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, cred, want)
+			// the formatted credentials must be equivalent to the RFC header
+			parsed, err := ParseCredentials(cred.String())
+			assert.NilError(t, err)
+			assert.DeepEqual(t, parsed, want)
+		})
+	}
+}
+
+func TestRFCTransport(t *testing.T) {
+	for _, tt := range rfcVectors {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := ParseCredentials(tt.authorization)
+			assert.NilError(t, err)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if auth := r.Header.Get("Authorization"); auth != "" {
+					cred, err := ParseCredentials(auth)
+					if err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+					if cred.Username == want.Username && cred.Response == want.Response {
+						io.WriteString(w, "Hello World")
+						return
+					}
+				}
+				w.Header().Add("WWW-Authenticate", tt.challenge)
+				w.WriteHeader(http.StatusUnauthorized)
+			}))
+			defer ts.Close()
+			client := http.Client{
+				Transport: &Transport{
+					Username: tt.username,
+					Password: tt.password,
+					Digest: func(_ *http.Request, chal *Challenge, opt Options) (*Credentials, error) {
+						// use the RFC's client nonce so the response is deterministic
+						opt.Cnonce = want.Cnonce
+						return Digest(chal, opt)
+					},
+				},
+			}
+			req, err := http.NewRequest(tt.method, ts.URL+want.URI, nil)
+			assert.NilError(t, err)
+			res, err := client.Do(req)
+			assert.NilError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, res.StatusCode, http.StatusOK)
+		})
+	}
+}
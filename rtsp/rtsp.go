@@ -0,0 +1,196 @@
+// Package rtsp provides a minimal RTSP client which answers digest challenges.
+package rtsp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/icholy/digest"
+)
+
+// Request is an RTSP request
+type Request struct {
+	Method string
+	URI    string
+	Header http.Header
+	Body   []byte
+}
+
+// Write writes the request in wire format
+func (r *Request) Write(w io.Writer) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s RTSP/1.0\r\n", r.Method, r.URI)
+	if err := r.Header.Write(&b); err != nil {
+		return err
+	}
+	if len(r.Body) > 0 && r.Header.Get("Content-Length") == "" {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n", len(r.Body))
+	}
+	b.WriteString("\r\n")
+	b.Write(r.Body)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// Response is an RTSP response
+type Response struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+}
+
+// MaxBodySize is the maximum size of a response body
+const MaxBodySize = 10 << 20
+
+// ReadResponse reads an RTSP response. Responses with a body
+// larger than MaxBodySize are rejected.
+func ReadResponse(r *bufio.Reader) (*Response, error) {
+	tp := textproto.NewReader(r)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	proto, status, ok := strings.Cut(line, " ")
+	if !ok || !strings.HasPrefix(proto, "RTSP/") {
+		return nil, fmt.Errorf("rtsp: malformed status line: %q", line)
+	}
+	code, _, _ := strings.Cut(status, " ")
+	statusCode, err := strconv.Atoi(code)
+	if err != nil {
+		return nil, fmt.Errorf("rtsp: malformed status code: %q", code)
+	}
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	res := &Response{
+		StatusCode: statusCode,
+		Status:     status,
+		Header:     http.Header(header),
+	}
+	if cl := res.Header.Get("Content-Length"); cl != "" {
+		n, err := strconv.Atoi(cl)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("rtsp: malformed content length: %q", cl)
+		}
+		if n > MaxBodySize {
+			return nil, fmt.Errorf("rtsp: content length %d exceeds the maximum of %d", n, MaxBodySize)
+		}
+		res.Body, err = io.ReadAll(io.LimitReader(r, int64(n)))
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Body) != n {
+			return nil, io.ErrUnexpectedEOF
+		}
+	}
+	return res, nil
+}
+
+// Client sends RTSP requests over a single connection. The digest challenge
+// and nonce count are tracked across requests so that a single challenge is
+// used for the DESCRIBE, SETUP, and PLAY requests. The session identifier
+// returned by the server is added to subsequent requests.
+type Client struct {
+	Username string
+	Password string
+
+	mu      sync.Mutex
+	conn    io.ReadWriter
+	br      *bufio.Reader
+	cseq    int
+	chal    *digest.Challenge
+	count   int
+	session string
+}
+
+// NewClient returns a client which uses the provided connection
+func NewClient(conn io.ReadWriter, username, password string) *Client {
+	return &Client{
+		Username: username,
+		Password: password,
+		conn:     conn,
+		br:       bufio.NewReader(conn),
+	}
+}
+
+// Do sends the request and returns the response. If the server responds with a
+// 401, the request is sent again with credentials computed from the challenge.
+func (c *Client) Do(req *Request) (*Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res, err := c.roundTrip(req)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+	chal, err := digest.FindChallenge(res.Header)
+	if err != nil {
+		if err == digest.ErrNoChallenge {
+			return res, nil
+		}
+		return nil, err
+	}
+	c.chal = chal
+	c.count = 0
+	return c.roundTrip(req)
+}
+
+// roundTrip writes the request with the current credentials and reads the response.
+// The caller must hold the lock.
+func (c *Client) roundTrip(req *Request) (*Response, error) {
+	header := req.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	// some servers require the exact CSeq capitalization
+	c.cseq++
+	header.Del("CSeq")
+	header["CSeq"] = []string{strconv.Itoa(c.cseq)}
+	if c.session != "" && header.Get("Session") == "" {
+		header.Set("Session", c.session)
+	}
+	if c.chal != nil {
+		c.count++
+		cred, err := digest.Digest(c.chal, digest.Options{
+			Method: req.Method,
+			URI:    req.URI,
+			GetBody: func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(req.Body)), nil
+			},
+			Count:    c.count,
+			Username: c.Username,
+			Password: c.Password,
+		})
+		if err != nil {
+			return nil, err
+		}
+		header.Set("Authorization", cred.String())
+	}
+	out := &Request{
+		Method: req.Method,
+		URI:    req.URI,
+		Header: header,
+		Body:   req.Body,
+	}
+	if err := out.Write(c.conn); err != nil {
+		return nil, err
+	}
+	res, err := ReadResponse(c.br)
+	if err != nil {
+		return nil, err
+	}
+	if session := res.Header.Get("Session"); session != "" {
+		// strip the timeout parameter
+		id, _, _ := strings.Cut(session, ";")
+		c.session = strings.TrimSpace(id)
+	}
+	return res, nil
+}
@@ -0,0 +1,131 @@
+package rtsp
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/icholy/digest"
+	"gotest.tools/v3/assert"
+)
+
+// serve is a fake RTSP server which requires digest authentication
+func serve(t *testing.T, conn net.Conn, chal *digest.Challenge, counts chan<- int) {
+	defer conn.Close()
+	defer close(counts)
+	tp := textproto.NewReader(bufio.NewReader(conn))
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		method, rest, _ := strings.Cut(line, " ")
+		uri, _, _ := strings.Cut(rest, " ")
+		header, err := tp.ReadMIMEHeader()
+		if !assert.Check(t, err) {
+			return
+		}
+		cseq := header.Get("CSeq")
+		if auth := header.Get("Authorization"); auth != "" {
+			cred, err := digest.ParseCredentials(auth)
+			if !assert.Check(t, err) {
+				return
+			}
+			expected, err := digest.Digest(chal, digest.Options{
+				Method:   method,
+				URI:      uri,
+				Cnonce:   cred.Cnonce,
+				Count:    cred.Nc,
+				Username: "admin",
+				Password: "12345",
+			})
+			if !assert.Check(t, err) {
+				return
+			}
+			if cred.URI == uri && cred.Response == expected.Response {
+				counts <- cred.Nc
+				if method == "PLAY" && header.Get("Session") != "12345678" {
+					fmt.Fprintf(conn, "RTSP/1.0 454 Session Not Found\r\nCSeq: %s\r\n\r\n", cseq)
+					continue
+				}
+				fmt.Fprintf(conn, "RTSP/1.0 200 OK\r\nCSeq: %s\r\nSession: 12345678;timeout=60\r\nContent-Length: 5\r\n\r\nhello", cseq)
+				continue
+			}
+		}
+		fmt.Fprintf(conn, "RTSP/1.0 401 Unauthorized\r\nCSeq: %s\r\nWWW-Authenticate: %s\r\n\r\n", cseq, chal)
+	}
+}
+
+func TestClient(t *testing.T) {
+	chal := &digest.Challenge{
+		Realm: "IP Camera",
+		Nonce: "4e6a49304e7a49314d5449364e4451344e4441344e54413d",
+		QOP:   []string{"auth"},
+	}
+	client, server := net.Pipe()
+	counts := make(chan int, 10)
+	go serve(t, server, chal, counts)
+	c := NewClient(client, "admin", "12345")
+	for _, req := range []*Request{
+		{Method: "DESCRIBE", URI: "rtsp://camera/stream1", Header: http.Header{"Accept": {"application/sdp"}}},
+		{Method: "SETUP", URI: "rtsp://camera/stream1/trackID=1", Header: http.Header{"Transport": {"RTP/AVP/TCP;unicast;interleaved=0-1"}}},
+		{Method: "PLAY", URI: "rtsp://camera/stream1"},
+	} {
+		res, err := c.Do(req)
+		assert.NilError(t, err)
+		assert.Equal(t, res.StatusCode, http.StatusOK, req.Method)
+		assert.Equal(t, string(res.Body), "hello")
+	}
+	client.Close()
+	var nc []int
+	for n := range counts {
+		nc = append(nc, n)
+	}
+	assert.DeepEqual(t, nc, []int{1, 2, 3})
+}
+
+func TestReadResponse(t *testing.T) {
+	input := "RTSP/1.0 401 Unauthorized\r\n" +
+		"CSeq: 1\r\n" +
+		`WWW-Authenticate: Digest realm="IP Camera", nonce="abc"` + "\r\n" +
+		`WWW-Authenticate: Basic realm="IP Camera"` + "\r\n" +
+		"\r\n"
+	res, err := ReadResponse(bufio.NewReader(strings.NewReader(input)))
+	assert.NilError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+	assert.Equal(t, res.Status, "401 Unauthorized")
+	chal, err := digest.FindChallenge(res.Header)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, chal, &digest.Challenge{Realm: "IP Camera", Nonce: "abc"})
+}
+
+func TestReadResponseContentLength(t *testing.T) {
+	tests := []struct {
+		length string
+		body   string
+		err    string
+	}{
+		{length: "5", body: "hello"},
+		{length: "9223372036854775807", err: "exceeds the maximum"},
+		{length: "-1", err: "malformed content length"},
+		{length: "10", body: "hello", err: "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.length, func(t *testing.T) {
+			input := "RTSP/1.0 200 OK\r\n" +
+				"Content-Length: " + tt.length + "\r\n" +
+				"\r\n" + tt.body
+			res, err := ReadResponse(bufio.NewReader(strings.NewReader(input)))
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, string(res.Body), tt.body)
+		})
+	}
+}
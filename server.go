@@ -0,0 +1,343 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// User is the identity of a request which was authenticated by a Handler
+type User struct {
+	Username  string
+	Realm     string
+	Algorithm string
+
+	// Userhash is true if the client sent a hashed username
+	Userhash bool
+}
+
+type userKey struct{}
+
+// ContextWithUser returns a copy of the context which contains the user
+func ContextWithUser(ctx context.Context, u *User) context.Context {
+	return context.WithValue(ctx, userKey{}, u)
+}
+
+// UserFromContext returns the user which was authenticated by a Handler
+func UserFromContext(ctx context.Context) (*User, bool) {
+	u, ok := ctx.Value(userKey{}).(*User)
+	return u, ok
+}
+
+// Authorizer decides whether an authenticated user may perform the request
+type Authorizer func(r *http.Request, u *User) bool
+
+// AllowUsers returns an Authorizer which only allows the listed users
+func AllowUsers(usernames ...string) Authorizer {
+	return func(r *http.Request, u *User) bool {
+		return slices.Contains(usernames, u.Username)
+	}
+}
+
+// Require returns a handler which responds with 403 Forbidden unless the
+// authenticated user in the request context is allowed by the authorizer.
+// It's used to restrict routes behind a Handler to specific users or groups.
+func Require(a Authorizer, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, ok := UserFromContext(r.Context())
+		if !ok || !a(r, u) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Handler is middleware which requires digest authentication. The authenticated
+// user is added to the request context and can be retrieved with UserFromContext.
+type Handler struct {
+	// Handler receives the authenticated requests
+	Handler http.Handler
+
+	// Realm is the protection space
+	Realm string
+
+	// Lookup returns the password for the username.
+	// The ok return value is false if the user doesn't exist.
+	Lookup func(username string) (password string, ok bool)
+
+	// Userhash offers RFC 7616 username privacy. Clients which support it
+	// send H(username:realm) instead of the username.
+	Userhash bool
+
+	// LookupUserhash returns the username and password for a hashed username.
+	// It's required when Userhash is enabled. UserStore.LookupUserhash
+	// provides an indexed implementation.
+	LookupUserhash func(userhash, realm, algorithm string) (username, password string, ok bool)
+
+	// Algorithms are offered in order of preference.
+	// If empty, SHA-256 and MD5 are offered. MD5 is omitted if it's disabled.
+	Algorithms []string
+
+	// QOP are the offered qop values. The request body is buffered
+	// in memory to verify auth-int credentials.
+	// If empty, auth is offered.
+	QOP []string
+
+	// MaxBodySize is the maximum size of a request body which is buffered
+	// to verify auth-int credentials. Larger requests receive a 413 response.
+	// If zero, the limit is 10MB.
+	MaxBodySize int64
+
+	// NonceLifetime is how long a nonce can be used for. Expired nonces
+	// are rejected with a stale challenge.
+	// If zero, nonces expire after 5 minutes.
+	NonceLifetime time.Duration
+
+	// Authorizer decides whether the authenticated user may perform the request.
+	// Requests which aren't authorized receive a 403 Forbidden response.
+	// If nil, all authenticated users are authorized.
+	Authorizer Authorizer
+
+	// key signs the nonces so that only used nonces need to be stored
+	key     []byte
+	keyOnce sync.Once
+
+	mu    sync.Mutex
+	used  map[string]*snonce
+	swept time.Time
+}
+
+// snonce is the state of a nonce which has been used
+type snonce struct {
+	created time.Time
+	nc      int
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !IsDigest(auth) {
+		h.challenge(w, false)
+		return
+	}
+	cred, err := ParseCredentialsStrict(auth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	u, stale, err := h.verify(w, r, cred)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		h.challenge(w, stale)
+		return
+	}
+	if h.Authorizer != nil && !h.Authorizer(r, u) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if h.Handler != nil {
+		h.Handler.ServeHTTP(w, r.WithContext(ContextWithUser(r.Context(), u)))
+	}
+}
+
+// verify checks the credentials and returns the authenticated user.
+// The stale return value is true if the credentials were only rejected
+// because of the nonce.
+func (h *Handler) verify(w http.ResponseWriter, r *http.Request, cred *Credentials) (*User, bool, error) {
+	if !slices.ContainsFunc(h.algorithms(), func(a string) bool {
+		return strings.EqualFold(a, cred.Algorithm)
+	}) {
+		return nil, false, &VerifyError{Reason: ReasonAlgorithm}
+	}
+	if !slices.Contains(h.qop(), cred.QOP) {
+		return nil, false, &VerifyError{Reason: ReasonQOP}
+	}
+	if !MatchURI(cred.URI, r) {
+		return nil, false, &VerifyError{Reason: ReasonURI}
+	}
+	username, password, ok := h.lookup(cred)
+	if !ok {
+		return nil, false, &VerifyError{Reason: ReasonUsername}
+	}
+	// the body is only buffered when it's needed to verify the credentials
+	getbody := func() (io.ReadCloser, error) { return http.NoBody, nil }
+	if cred.QOP == "auth-int" && r.Body != nil {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodySize()))
+		if err != nil {
+			return nil, false, err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		getbody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	// the nonce is checked once the response has been verified
+	err := Verify(&Challenge{
+		Realm:     h.Realm,
+		Nonce:     cred.Nonce,
+		Algorithm: cred.Algorithm,
+		QOP:       h.qop(),
+		Userhash:  h.Userhash,
+	}, cred, Options{
+		Method:   r.Method,
+		URI:      cred.URI,
+		GetBody:  getbody,
+		Username: username,
+		Password: password,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if !h.use(cred.Nonce, cred.Nc) {
+		return nil, true, &VerifyError{Reason: ReasonNonce}
+	}
+	return &User{
+		Username:  username,
+		Realm:     h.Realm,
+		Algorithm: cred.Algorithm,
+		Userhash:  cred.Userhash,
+	}, false, nil
+}
+
+// lookup returns the username and password of the account the credentials belong to
+func (h *Handler) lookup(cred *Credentials) (username, password string, ok bool) {
+	if !cred.Userhash {
+		password, ok = h.Lookup(cred.Username)
+		return cred.Username, password, ok
+	}
+	if !h.Userhash || h.LookupUserhash == nil {
+		return "", "", false
+	}
+	return h.LookupUserhash(cred.Username, h.Realm, cred.Algorithm)
+}
+
+// use records a use of the nonce. It returns false if the nonce
+// is invalid or expired, or if the nonce count was already used.
+func (h *Handler) use(nonce string, nc int) bool {
+	created, ok := h.parse(nonce)
+	if !ok || time.Since(created) > h.lifetime() {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// expired nonces are discarded at most once per lifetime
+	if time.Since(h.swept) > h.lifetime() {
+		for k, n := range h.used {
+			if time.Since(n.created) > h.lifetime() {
+				delete(h.used, k)
+			}
+		}
+		h.swept = time.Now()
+	}
+	if h.used == nil {
+		h.used = map[string]*snonce{}
+	}
+	n, ok := h.used[nonce]
+	if !ok {
+		n = &snonce{created: created}
+		h.used[nonce] = n
+	}
+	if nc <= n.nc {
+		return false
+	}
+	n.nc = nc
+	return true
+}
+
+// challenge writes a 401 response with a challenge for each algorithm
+func (h *Handler) challenge(w http.ResponseWriter, stale bool) {
+	nonce := h.nonce()
+	for _, algorithm := range h.algorithms() {
+		w.Header().Add("WWW-Authenticate", (&Challenge{
+			Realm:     h.Realm,
+			Nonce:     nonce,
+			Stale:     stale,
+			Algorithm: algorithm,
+			QOP:       h.qop(),
+			Userhash:  h.Userhash,
+		}).String())
+	}
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// nonce issues a new nonce. Nonces contain their creation time and a
+// signature, so they don't need to be stored until they're used.
+func (h *Handler) nonce() string {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(time.Now().UnixNano()))
+	return hex.EncodeToString(h.sign(b[:]))
+}
+
+// parse verifies the nonce signature and returns the creation time
+func (h *Handler) parse(nonce string) (time.Time, bool) {
+	b, err := hex.DecodeString(nonce)
+	if err != nil || len(b) != 8+nonceMACSize || !hmac.Equal(b, h.sign(b[:8])) {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(b[:8]))), true
+}
+
+// nonceMACSize is the number of signature bytes in a nonce
+const nonceMACSize = 16
+
+// sign appends the truncated HMAC of the timestamp and realm to the timestamp
+func (h *Handler) sign(timestamp []byte) []byte {
+	h.keyOnce.Do(func() {
+		h.key = make([]byte, 32)
+		if _, err := rand.Read(h.key); err != nil {
+			panic(fmt.Sprintf("digest: failed to generate nonce key: %v", err))
+		}
+	})
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write(timestamp)
+	mac.Write([]byte(h.Realm))
+	return append(slices.Clone(timestamp), mac.Sum(nil)[:nonceMACSize]...)
+}
+
+func (h *Handler) algorithms() []string {
+	if len(h.Algorithms) > 0 {
+		return h.Algorithms
+	}
+	if MD5Disabled() {
+		return []string{"SHA-256"}
+	}
+	return []string{"SHA-256", "MD5"}
+}
+
+func (h *Handler) qop() []string {
+	if len(h.QOP) > 0 {
+		return h.QOP
+	}
+	return []string{"auth"}
+}
+
+func (h *Handler) maxBodySize() int64 {
+	if h.MaxBodySize > 0 {
+		return h.MaxBodySize
+	}
+	return 10 << 20
+}
+
+func (h *Handler) lifetime() time.Duration {
+	if h.NonceLifetime > 0 {
+		return h.NonceLifetime
+	}
+	return 5 * time.Minute
+}
@@ -0,0 +1,236 @@
+package digest_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icholy/digest"
+	"gotest.tools/v3/assert"
+)
+
+func lookup(username string) (string, bool) {
+	password, ok := map[string]string{
+		"alice": "secret",
+		"bob":   "hunter2",
+	}[username]
+	return password, ok
+}
+
+func TestHandler(t *testing.T) {
+	var user *digest.User
+	ts := httptest.NewServer(&digest.Handler{
+		Realm:  "test",
+		Lookup: lookup,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var ok bool
+			user, ok = digest.UserFromContext(r.Context())
+			assert.Check(t, ok)
+			io.WriteString(w, "Hello World")
+		}),
+	})
+	defer ts.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username: "alice",
+			Password: "secret",
+		},
+	}
+	for range 3 {
+		getOK(t, client, ts.URL)
+	}
+	assert.DeepEqual(t, user, &digest.User{
+		Username:  "alice",
+		Realm:     "test",
+		Algorithm: "SHA-256",
+	})
+	// wrong password
+	client.Transport = &digest.Transport{
+		Username: "alice",
+		Password: "wrong",
+	}
+	res, err := client.Get(ts.URL)
+	assert.NilError(t, err)
+	res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+}
+
+func TestHandlerReplay(t *testing.T) {
+	ts := httptest.NewServer(&digest.Handler{
+		Realm:  "test",
+		Lookup: lookup,
+	})
+	defer ts.Close()
+	var auth string
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username: "bob",
+			Password: "hunter2",
+			Trace: &digest.ClientTrace{
+				OnRetry: func(req *http.Request, res *http.Response) {
+					auth = req.Header.Get("Authorization")
+				},
+			},
+		},
+	}
+	getOK(t, client, ts.URL)
+	// replaying the credentials is rejected
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	assert.NilError(t, err)
+	req.Header.Set("Authorization", auth)
+	res, err := http.DefaultClient.Do(req)
+	assert.NilError(t, err)
+	res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+	// replaying them for another resource is rejected
+	req, err = http.NewRequest(http.MethodGet, ts.URL+"/other", nil)
+	assert.NilError(t, err)
+	req.Header.Set("Authorization", auth)
+	res, err = http.DefaultClient.Do(req)
+	assert.NilError(t, err)
+	res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+}
+
+func TestHandlerStale(t *testing.T) {
+	ts := httptest.NewServer(&digest.Handler{
+		Realm:         "test",
+		Lookup:        lookup,
+		NonceLifetime: 50 * time.Millisecond,
+	})
+	defer ts.Close()
+	var challenges int
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username: "bob",
+			Password: "hunter2",
+			Trace: &digest.ClientTrace{
+				OnChallenge: func(req *http.Request, chal *digest.Challenge) {
+					challenges++
+					assert.Equal(t, chal.Stale, challenges > 1)
+				},
+			},
+		},
+	}
+	getOK(t, client, ts.URL)
+	time.Sleep(100 * time.Millisecond)
+	getOK(t, client, ts.URL)
+	assert.Equal(t, challenges, 2)
+}
+
+func TestHandlerAuthorizer(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	mux.Handle("/admin", digest.Require(digest.AllowUsers("alice"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	ts := httptest.NewServer(&digest.Handler{
+		Realm:   "test",
+		Lookup:  lookup,
+		Handler: mux,
+	})
+	defer ts.Close()
+	tests := []struct {
+		username string
+		password string
+		path     string
+		status   int
+	}{
+		{"alice", "secret", "/", http.StatusOK},
+		{"alice", "secret", "/admin", http.StatusOK},
+		{"bob", "hunter2", "/", http.StatusOK},
+		{"bob", "hunter2", "/admin", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		client := &http.Client{
+			Transport: &digest.Transport{
+				Username: tt.username,
+				Password: tt.password,
+			},
+		}
+		res, err := client.Get(ts.URL + tt.path)
+		assert.NilError(t, err)
+		res.Body.Close()
+		assert.Equal(t, res.StatusCode, tt.status, "%s %s", tt.username, tt.path)
+	}
+}
+
+func TestHandlerAuthInt(t *testing.T) {
+	tests := []struct {
+		name   string
+		qop    []string
+		body   string
+		status int
+	}{
+		{"offered", []string{"auth-int"}, "hello", http.StatusOK},
+		{"not offered", []string{"auth"}, strings.Repeat("x", 100), http.StatusUnauthorized},
+		{"too large", []string{"auth-int"}, strings.Repeat("x", 100), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(&digest.Handler{
+				Realm:       "test",
+				Lookup:      lookup,
+				QOP:         tt.qop,
+				MaxBodySize: 10,
+			})
+			defer ts.Close()
+			res, err := http.Get(ts.URL)
+			assert.NilError(t, err)
+			res.Body.Close()
+			chal, err := digest.FindChallenge(res.Header)
+			assert.NilError(t, err)
+			// the client sends auth-int even if it wasn't offered
+			chal.QOP = []string{"auth-int"}
+			cred, err := digest.Digest(chal, digest.Options{
+				Method: http.MethodPost,
+				URI:    "/",
+				GetBody: func() (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader(tt.body)), nil
+				},
+				Count:    1,
+				Username: "alice",
+				Password: "secret",
+			})
+			assert.NilError(t, err)
+			req, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(tt.body))
+			assert.NilError(t, err)
+			req.Header.Set("Authorization", cred.String())
+			res, err = http.DefaultClient.Do(req)
+			assert.NilError(t, err)
+			res.Body.Close()
+			assert.Equal(t, res.StatusCode, tt.status)
+		})
+	}
+}
+
+func TestHandlerForgedNonce(t *testing.T) {
+	ts := httptest.NewServer(&digest.Handler{
+		Realm:  "test",
+		Lookup: lookup,
+	})
+	defer ts.Close()
+	res, err := http.Get(ts.URL)
+	assert.NilError(t, err)
+	res.Body.Close()
+	chal, err := digest.FindChallenge(res.Header)
+	assert.NilError(t, err)
+	// change the timestamp without updating the signature
+	chal.Nonce = "ff" + chal.Nonce[2:]
+	cred, err := digest.Digest(chal, digest.Options{
+		Method:   http.MethodGet,
+		URI:      "/",
+		Count:    1,
+		Username: "alice",
+		Password: "secret",
+	})
+	assert.NilError(t, err)
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	assert.NilError(t, err)
+	req.Header.Set("Authorization", cred.String())
+	res, err = http.DefaultClient.Do(req)
+	assert.NilError(t, err)
+	res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+}
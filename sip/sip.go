@@ -0,0 +1,125 @@
+// Package sip provides helpers for answering SIP digest challenges
+// as described in RFC 3261 and RFC 8760.
+package sip
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/icholy/digest"
+)
+
+// Headers returns the name of the challenge header and the name of the
+// corresponding credentials header for the response status code.
+func Headers(status int) (challenge, credentials string) {
+	if status == http.StatusProxyAuthRequired {
+		return "Proxy-Authenticate", "Proxy-Authorization"
+	}
+	return "WWW-Authenticate", "Authorization"
+}
+
+// Challenges returns a supported challenge for every realm in the response
+// headers. Servers list challenges in order of preference, so the first
+// supported challenge for each realm is selected.
+func Challenges(status int, h http.Header) ([]*digest.Challenge, error) {
+	name, _ := Headers(status)
+	var chals []*digest.Challenge
+	realms := map[string]bool{}
+	var last error
+	for _, header := range h.Values(name) {
+		if !digest.IsDigest(header) {
+			continue
+		}
+		chal, err := digest.ParseChallenge(header)
+		if err != nil {
+			last = err
+			continue
+		}
+		if realms[chal.Realm] || !digest.CanDigest(chal) {
+			continue
+		}
+		realms[chal.Realm] = true
+		chals = append(chals, chal)
+	}
+	if len(chals) == 0 {
+		if last != nil {
+			return nil, last
+		}
+		return nil, digest.ErrNoChallenge
+	}
+	return chals, nil
+}
+
+// Options for answering SIP challenges
+type Options struct {
+	// Method is the request method such as REGISTER or INVITE.
+	Method string
+
+	// URI is the Request-URI.
+	URI string
+
+	// Body is the message body used for auth-int.
+	Body []byte
+
+	// Count is the nonce count. If zero, 1 is used.
+	Count int
+
+	// Cnonce overrides the generated client nonce.
+	Cnonce string
+
+	// Lookup returns the username and password for a realm.
+	// Realms without credentials are not answered.
+	Lookup func(realm string) (username, password string, ok bool)
+}
+
+// Answer computes credentials for every challenge in the response which
+// the Lookup function has credentials for.
+func Answer(status int, h http.Header, opt Options) ([]*digest.Credentials, error) {
+	chals, err := Challenges(status, h)
+	if err != nil {
+		return nil, err
+	}
+	var creds []*digest.Credentials
+	for _, chal := range chals {
+		username, password, ok := opt.Lookup(chal.Realm)
+		if !ok {
+			continue
+		}
+		cred, err := digest.Digest(chal, digest.Options{
+			Method: opt.Method,
+			URI:    opt.URI,
+			GetBody: func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(opt.Body)), nil
+			},
+			Count:    opt.Count,
+			Cnonce:   opt.Cnonce,
+			Username: username,
+			Password: password,
+		})
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, cred)
+	}
+	if len(creds) == 0 {
+		return nil, errors.New("sip: no credentials for challenged realms")
+	}
+	return creds, nil
+}
+
+// Authorize answers every challenge in the response and adds the credentials
+// to the request headers using the header name which matches the status code.
+func Authorize(req http.Header, status int, res http.Header, opt Options) error {
+	creds, err := Answer(status, res, opt)
+	if err != nil {
+		return err
+	}
+	_, name := Headers(status)
+	req.Del(name)
+	for _, cred := range creds {
+		req.Add(name, cred.String())
+	}
+	return nil
+}
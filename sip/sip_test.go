@@ -0,0 +1,96 @@
+package sip
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/icholy/digest"
+	"gotest.tools/v3/assert"
+)
+
+func TestAuthorizeRegister(t *testing.T) {
+	res := http.Header{}
+	res.Add("WWW-Authenticate", `Digest realm="SipPeer", nonce="970a1b42-d8a7-4fce-91a1-4767e9ed561b", qop="auth"`)
+	req := http.Header{}
+	err := Authorize(req, http.StatusUnauthorized, res, Options{
+		Method: "REGISTER",
+		URI:    "sip:182.82.132.122",
+		Cnonce: "104adc6bd71f49678798ee646edcaa9a",
+		Lookup: func(realm string) (string, string, bool) {
+			return "the-user", "********", realm == "SipPeer"
+		},
+	})
+	assert.NilError(t, err)
+	cred, err := digest.ParseCredentials(req.Get("Authorization"))
+	assert.NilError(t, err)
+	assert.Equal(t, cred.URI, "sip:182.82.132.122")
+	assert.Equal(t, cred.Response, "6cf0981e056709d40c8acc40c87e73c6")
+}
+
+func TestAuthorizeProxyMultiRealm(t *testing.T) {
+	res := http.Header{}
+	res.Add("Proxy-Authenticate", `Digest realm="atlanta.com", nonce="wf84f1ceczx41ae6cbe5aea9c8e88d359", algorithm=SHA-256, qop="auth"`)
+	res.Add("Proxy-Authenticate", `Digest realm="atlanta.com", nonce="wf84f1ceczx41ae6cbe5aea9c8e88d359", algorithm=MD5, qop="auth"`)
+	res.Add("Proxy-Authenticate", `Digest realm="biloxi.com", nonce="c60f3082ee1212b402a21831ae", algorithm=SHA-512-256, qop="auth"`)
+	res.Add("Proxy-Authenticate", `Digest realm="unknown.com", nonce="1234", algorithm=MD5`)
+	// challenges from the wrong header are ignored
+	res.Add("WWW-Authenticate", `Digest realm="other.com", nonce="5678"`)
+	users := map[string]string{
+		"atlanta.com": "alice",
+		"biloxi.com":  "bob",
+	}
+	req := http.Header{}
+	err := Authorize(req, http.StatusProxyAuthRequired, res, Options{
+		Method: "INVITE",
+		URI:    "sip:bob@biloxi.com",
+		Lookup: func(realm string) (string, string, bool) {
+			username, ok := users[realm]
+			return username, "secret", ok
+		},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, req.Get("Authorization"), "")
+	values := req.Values("Proxy-Authorization")
+	assert.Equal(t, len(values), 2)
+	for i, want := range []struct {
+		username, realm, algorithm string
+	}{
+		{"alice", "atlanta.com", "SHA-256"},
+		{"bob", "biloxi.com", "SHA-512-256"},
+	} {
+		cred, err := digest.ParseCredentials(values[i])
+		assert.NilError(t, err)
+		assert.Equal(t, cred.Username, want.username)
+		assert.Equal(t, cred.Realm, want.realm)
+		assert.Equal(t, cred.Algorithm, want.algorithm)
+		assert.Equal(t, cred.URI, "sip:bob@biloxi.com")
+		expected, err := digest.Digest(&digest.Challenge{
+			Realm:     cred.Realm,
+			Nonce:     cred.Nonce,
+			Algorithm: cred.Algorithm,
+			QOP:       []string{cred.QOP},
+		}, digest.Options{
+			Method:   "INVITE",
+			URI:      cred.URI,
+			Cnonce:   cred.Cnonce,
+			Count:    cred.Nc,
+			Username: want.username,
+			Password: "secret",
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, cred.Response, expected.Response)
+	}
+}
+
+func TestAuthorizeNoCredentials(t *testing.T) {
+	res := http.Header{}
+	res.Add("WWW-Authenticate", `Digest realm="SipPeer", nonce="1234"`)
+	err := Authorize(http.Header{}, http.StatusUnauthorized, res, Options{
+		Method: "REGISTER",
+		URI:    "sip:example.com",
+		Lookup: func(realm string) (string, string, bool) {
+			return "", "", false
+		},
+	})
+	assert.ErrorContains(t, err, "no credentials")
+}
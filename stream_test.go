@@ -0,0 +1,190 @@
+package digest_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/icholy/digest"
+	"github.com/icholy/digest/digesttest"
+	"gotest.tools/v3/assert"
+)
+
+func TestTransportStream(t *testing.T) {
+	received := make(chan []byte, 1)
+	chunk := make(chan struct{})
+	s := digesttest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		buf := make([]byte, 5)
+		_, err := io.ReadFull(r.Body, buf)
+		if !assert.Check(t, err) {
+			received <- nil
+			return
+		}
+		close(chunk)
+		rest, err := io.ReadAll(r.Body)
+		assert.Check(t, err)
+		received <- append(buf, rest...)
+	}), map[string]string{"foo": "bar"})
+	defer s.Close()
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username:    "foo",
+			Password:    "bar",
+			ProbeMethod: http.MethodHead,
+		},
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		io.WriteString(pw, "Hello")
+		// the rest of the body is only written once the server
+		// has received the first chunk, so it can't be buffered
+		select {
+		case <-chunk:
+			io.WriteString(pw, " World")
+			pw.Close()
+		case <-time.After(5 * time.Second):
+			pw.CloseWithError(io.ErrUnexpectedEOF)
+		}
+	}()
+	req, err := http.NewRequest(http.MethodPost, s.URL, pr)
+	assert.NilError(t, err)
+	res, err := client.Do(req)
+	assert.NilError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, string(<-received), "Hello World")
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, s.Challenges(), 1)
+}
+
+func TestTransportStreamReuse(t *testing.T) {
+	tests := []struct {
+		name      string
+		transport *digest.Transport
+	}{
+		{
+			name:      "max nonce uses",
+			transport: &digest.Transport{MaxNonceUses: 2},
+		},
+		{
+			name:      "serialize",
+			transport: &digest.Transport{Serialize: true, PoolSize: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := digesttest.NewServer(nil, map[string]string{"foo": "bar"})
+			defer s.Close()
+			tt.transport.Username = "foo"
+			tt.transport.Password = "bar"
+			tt.transport.ProbeMethod = http.MethodHead
+			client := &http.Client{Transport: tt.transport}
+			for range 3 {
+				pr, pw := io.Pipe()
+				go func() {
+					io.WriteString(pw, "Hello World")
+					pw.Close()
+				}()
+				req, err := http.NewRequest(http.MethodPost, s.URL, pr)
+				assert.NilError(t, err)
+				res, err := client.Do(req)
+				assert.NilError(t, err)
+				res.Body.Close()
+				assert.Equal(t, res.StatusCode, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestTransportStreamUnsupported(t *testing.T) {
+	tests := []struct {
+		name    string
+		noReuse bool
+		qop     []string
+	}{
+		{name: "no reuse", noReuse: true, qop: []string{"auth"}},
+		{name: "auth-int", qop: []string{"auth-int"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := digesttest.NewUnstartedServer(nil, map[string]string{"foo": "bar"})
+			s.QOP = tt.qop
+			s.Start()
+			defer s.Close()
+			client := &http.Client{
+				Transport: &digest.Transport{
+					Username:    "foo",
+					Password:    "bar",
+					ProbeMethod: http.MethodHead,
+					NoReuse:     tt.noReuse,
+				},
+			}
+			pr, pw := io.Pipe()
+			defer pw.Close()
+			req, err := http.NewRequest(http.MethodPost, s.URL, pr)
+			assert.NilError(t, err)
+			_, err = client.Do(req)
+			assert.ErrorContains(t, err, "ProbeMethod cannot be used")
+		})
+	}
+}
+
+func TestTransportStreamTrace(t *testing.T) {
+	s := digesttest.NewServer(nil, map[string]string{"foo": "bar"})
+	defer s.Close()
+	var events []string
+	var logs bytes.Buffer
+	tr := &digest.Transport{
+		Username:    "foo",
+		Password:    "bar",
+		ProbeMethod: http.MethodHead,
+		Logger:      slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		Trace: &digest.ClientTrace{
+			OnChallenge: func(req *http.Request, chal *digest.Challenge) {
+				events = append(events, "challenge "+req.Method)
+			},
+			OnCacheHit: func(req *http.Request, chal *digest.Challenge, count int) {
+				events = append(events, fmt.Sprintf("hit %s %d", req.Method, count))
+			},
+			OnCacheMiss: func(req *http.Request) {
+				events = append(events, "miss "+req.Method)
+			},
+			OnAuthFailure: func(req *http.Request, res *http.Response, err error) {
+				events = append(events, fmt.Sprintf("failure %s %v", req.Method, err))
+			},
+		},
+	}
+	client := &http.Client{Transport: tr}
+	post := func() int {
+		pr, pw := io.Pipe()
+		go func() {
+			io.WriteString(pw, "Hello World")
+			pw.Close()
+		}()
+		req, err := http.NewRequest(http.MethodPost, s.URL, pr)
+		assert.NilError(t, err)
+		res, err := client.Do(req)
+		assert.NilError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	assert.Equal(t, post(), http.StatusOK)
+	tr.Password = "wrong"
+	assert.Equal(t, post(), http.StatusUnauthorized)
+	assert.DeepEqual(t, events, []string{
+		"miss HEAD",
+		"challenge HEAD",
+		"hit POST 2",
+		"hit POST 3",
+		"challenge POST",
+		"failure POST <nil>",
+	})
+	assert.Assert(t, strings.Contains(logs.String(), `msg="digest: credentials rejected"`), logs.String())
+}
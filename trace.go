@@ -0,0 +1,87 @@
+package digest
+
+import (
+	"context"
+	"net/http"
+)
+
+// ClientTrace is a set of hooks to run at various stages of the digest
+// authentication process. Any particular hook may be nil. The OnChallenge
+// and OnCacheHit hooks are only invoked for digest challenges.
+type ClientTrace struct {
+	// OnChallenge is called when a usable challenge is received in a 401 response.
+	OnChallenge func(req *http.Request, chal *Challenge)
+
+	// OnCacheHit is called when a cached challenge is used to authorize a request.
+	// The count is the number of times the challenge has been used.
+	OnCacheHit func(req *http.Request, chal *Challenge, count int)
+
+	// OnCacheMiss is called when there's no cached challenge for a request.
+	OnCacheMiss func(req *http.Request)
+
+	// OnRetry is called before a request is retried in response to a 401.
+	OnRetry func(req *http.Request, res *http.Response)
+
+	// OnAuthFailure is called when a 401 response cannot be handled. The err is nil
+	// when the server rejected the credentials computed from a fresh challenge.
+	OnAuthFailure func(req *http.Request, res *http.Response, err error)
+}
+
+type clientTraceKey struct{}
+
+// WithClientTrace returns a new context based on the provided parent ctx.
+// Requests made by a Transport with the returned context will use the provided
+// trace hooks in addition to the Transport's own trace.
+func WithClientTrace(ctx context.Context, trace *ClientTrace) context.Context {
+	return context.WithValue(ctx, clientTraceKey{}, trace)
+}
+
+// ContextClientTrace returns the ClientTrace associated with the provided
+// context. If none, it returns nil.
+func ContextClientTrace(ctx context.Context) *ClientTrace {
+	trace, _ := ctx.Value(clientTraceKey{}).(*ClientTrace)
+	return trace
+}
+
+// traces invokes the hooks of multiple ClientTrace values
+type traces []*ClientTrace
+
+func (tt traces) challenge(req *http.Request, chal *Challenge) {
+	for _, t := range tt {
+		if t.OnChallenge != nil {
+			t.OnChallenge(req, chal)
+		}
+	}
+}
+
+func (tt traces) cacheHit(req *http.Request, chal *Challenge, count int) {
+	for _, t := range tt {
+		if t.OnCacheHit != nil {
+			t.OnCacheHit(req, chal, count)
+		}
+	}
+}
+
+func (tt traces) cacheMiss(req *http.Request) {
+	for _, t := range tt {
+		if t.OnCacheMiss != nil {
+			t.OnCacheMiss(req)
+		}
+	}
+}
+
+func (tt traces) retry(req *http.Request, res *http.Response) {
+	for _, t := range tt {
+		if t.OnRetry != nil {
+			t.OnRetry(req, res)
+		}
+	}
+}
+
+func (tt traces) authFailure(req *http.Request, res *http.Response, err error) {
+	for _, t := range tt {
+		if t.OnAuthFailure != nil {
+			t.OnAuthFailure(req, res, err)
+		}
+	}
+}
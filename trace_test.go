@@ -0,0 +1,70 @@
+package digest_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/icholy/digest"
+	"github.com/icholy/digest/digesttest"
+	"gotest.tools/v3/assert"
+)
+
+func TestClientTrace(t *testing.T) {
+	var events []string
+	trace := &digest.ClientTrace{
+		OnChallenge: func(req *http.Request, chal *digest.Challenge) {
+			events = append(events, "challenge "+chal.Algorithm)
+		},
+		OnCacheHit: func(req *http.Request, chal *digest.Challenge, count int) {
+			events = append(events, fmt.Sprintf("hit %d", count))
+		},
+		OnCacheMiss: func(req *http.Request) {
+			events = append(events, "miss")
+		},
+		OnRetry: func(req *http.Request, res *http.Response) {
+			events = append(events, "retry")
+		},
+		OnAuthFailure: func(req *http.Request, res *http.Response, err error) {
+			events = append(events, fmt.Sprintf("failure %v", err))
+		},
+	}
+	s := digesttest.NewUnstartedServer(nil, map[string]string{"foo": "bar"})
+	s.Algorithms = []string{"SHA-256"}
+	s.NonceUses = 2
+	s.Start()
+	defer s.Close()
+	tr := &digest.Transport{
+		Username: "foo",
+		Password: "bar",
+		Trace:    trace,
+	}
+	client := &http.Client{Transport: tr}
+	for range 3 {
+		res, err := client.Get(s.URL)
+		assert.NilError(t, err)
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		assert.Equal(t, res.StatusCode, http.StatusOK)
+	}
+	assert.DeepEqual(t, events, []string{
+		"miss", "challenge SHA-256", "retry",
+		"hit 2",
+		"hit 3", "challenge SHA-256", "retry",
+	})
+	// wrong password with a per-request trace
+	events = nil
+	tr.Trace = nil
+	tr.Password = "wrong"
+	req, err := http.NewRequestWithContext(digest.WithClientTrace(context.Background(), trace), http.MethodGet, s.URL, nil)
+	assert.NilError(t, err)
+	res, err := client.Do(req)
+	assert.NilError(t, err)
+	res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+	assert.DeepEqual(t, events, []string{
+		"hit 2", "challenge SHA-256", "retry", "failure <nil>",
+	})
+}
@@ -2,15 +2,71 @@ package digest
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
+	"log/slog"
 	"net/http"
+	"slices"
 	"sync"
+	"time"
 )
 
-// cchal is a cached challenge and the number of times it's been used.
+// cchal is a cached challenge, the number of times it's been used,
+// and when it was received.
 type cchal struct {
-	c *Challenge
+	a AuthChallenge
 	n int
+	t time.Time
+
+	// holds a value while a request using the challenge is in flight
+	// when Transport.Serialize is enabled
+	sem chan struct{}
+}
+
+// newCchal returns a cached challenge which was received now
+func newCchal(a AuthChallenge) *cchal {
+	return &cchal{a: a, t: time.Now(), sem: make(chan struct{}, 1)}
+}
+
+// digest returns the digest challenge if there is one
+func (cc *cchal) digest() (*Challenge, bool) {
+	if dc, ok := cc.a.(*digestChallenge); ok {
+		return dc.chal, true
+	}
+	return nil, false
+}
+
+// cpool is a pool of cached challenges which are used round-robin.
+type cpool struct {
+	cc   []*cchal
+	next int
+}
+
+// get returns the next challenge in the pool
+func (p *cpool) get() *cchal {
+	if len(p.cc) == 0 {
+		return nil
+	}
+	cc := p.cc[p.next%len(p.cc)]
+	p.next++
+	return cc
+}
+
+// remove removes the challenge from the pool
+func (p *cpool) remove(cc *cchal) {
+	p.cc = slices.DeleteFunc(p.cc, func(c *cchal) bool {
+		return c == cc
+	})
+}
+
+// add adds the challenge to the pool and evicts the
+// oldest challenges if the pool exceeds the provided size
+func (p *cpool) add(cc *cchal, size int) {
+	p.cc = append(p.cc, cc)
+	if n := len(p.cc) - size; n > 0 {
+		p.cc = slices.Delete(p.cc, 0, n)
+	}
 }
 
 // Transport implements http.RoundTripper
@@ -45,28 +101,108 @@ type Transport struct {
 	// NoReuse prevents the transport from reusing challenges.
 	NoReuse bool
 
+	// MaxNonceUses is the maximum number of times a challenge is used before
+	// it's discarded. If zero, there is no limit.
+	MaxNonceUses int
+
+	// MaxNonceAge is the maximum age of a challenge before it's discarded.
+	// If zero, there is no limit.
+	MaxNonceAge time.Duration
+
+	// Serialize makes requests which reuse the same challenge wait for each
+	// other. This guarantees that nonce counts reach the server in order, which
+	// is required by servers that reject counts lower than one already seen.
+	// A waiting request returns the context's error if its context is done.
+	Serialize bool
+
+	// PoolSize is the maximum number of challenges cached per host.
+	// Challenges received by concurrent requests are added to the pool and
+	// cached challenges are used round-robin. When Serialize is enabled and the
+	// next challenge is in use, the request obtains a new challenge if the pool
+	// isn't full. If zero, a single challenge is cached.
+	PoolSize int
+
+	// Trace specifies hooks which are invoked for every request.
+	// Per-request hooks can be added with WithClientTrace.
+	Trace *ClientTrace
+
+	// Basic controls whether Basic challenges are answered when the server
+	// doesn't offer a digest challenge. The Username and Password are used.
+	// If zero, Basic challenges are never answered.
+	Basic BasicPolicy
+
+	// Policy specifies the minimum requirements for digest challenges.
+	// If nil, all supported challenges are accepted.
+	Policy *Policy
+
+	// PinAlgorithm remembers the strongest algorithm offered by each origin
+	// and refuses challenges which use a weaker algorithm afterwards.
+	// Basic challenges are not answered for origins which offered digest.
+	PinAlgorithm bool
+
+	// Quirks adjust the credentials for servers which don't comply with the RFCs.
+	// The Profiles provide quirks for documented failures.
+	Quirks Quirks
+
+	// Origins controls which origins credentials are sent to. This prevents
+	// credentials from being used to answer challenges from hosts which the
+	// client was redirected to.
+	// If nil, credentials are only sent to the origin of the initial request
+	// and to the same host over https.
+	Origins *OriginPolicy
+
+	// ProbeMethod enables streaming of request bodies which can't be replayed
+	// because GetBody is nil. Instead of buffering the body in memory, a request
+	// with the ProbeMethod (usually HEAD) and no body is used to obtain a challenge
+	// before the body is sent. The streamed request is not retried if it's rejected.
+	// An error is returned if NoReuse is set or the challenge requires auth-int.
+	// If empty, bodies are buffered.
+	ProbeMethod string
+
+	// Authenticators implement the supported authentication schemes. The first
+	// one which finds a supported challenge in a 401 response is used.
+	// If empty, digest authentication is performed using the Username, Password,
+	// Digest, FindChallenge, and Basic fields.
+	Authenticators []Authenticator
+
+	// Logger receives debug level logs about the authentication process.
+	// Passwords, responses, and A1 values are never logged.
+	// If nil, nothing is logged.
+	Logger *slog.Logger
+
 	// cache of challenges indexed by host
-	cache   map[string]*cchal
+	cache   map[string]*cpool
 	cacheMu sync.Mutex
+
+	// strongest algorithm offered by each origin when PinAlgorithm is enabled
+	pins   map[string]int
+	pinsMu sync.Mutex
 }
 
-// save parses the digest challenge from the response
-// and adds it to the cache
-func (t *Transport) save(res *http.Response) error {
+// save selects a challenge from the response and adds it to the cache.
+// The rejected challenge, if there was one, is removed from the cache.
+func (t *Transport) save(res *http.Response, rejected *cchal) (*cchal, error) {
 	// save cookies
 	if t.Jar != nil {
 		t.Jar.SetCookies(res.Request.URL, res.Cookies())
 	}
-	// find and save digest challenge
-	find := t.FindChallenge
-	if find == nil {
-		find = FindChallenge
+	// don't answer challenges from untrusted origins
+	if !t.Origins.allows(res.Request) {
+		return nil, ErrUntrustedOrigin
+	}
+	// find and save the challenge
+	var ac AuthChallenge
+	err := ErrNoChallenge
+	for _, a := range t.authenticators() {
+		ac, err = a.Challenge(res)
+		if err != ErrNoChallenge {
+			break
+		}
 	}
-	chal, err := find(res.Header)
 	t.cacheMu.Lock()
 	defer t.cacheMu.Unlock()
 	if t.cache == nil {
-		t.cache = map[string]*cchal{}
+		t.cache = map[string]*cpool{}
 	}
 	// TODO: if the challenge contains a domain, we should be using that
 	//       to match against outgoing requests. We're currently ignoring
@@ -76,47 +212,139 @@ func (t *Transport) save(res *http.Response) error {
 	if err != nil {
 		// if save is being invoked, the existing cached challenge didn't work
 		delete(t.cache, host)
-		return err
+		return nil, err
+	}
+	cc := newCchal(ac)
+	if chal, ok := cc.digest(); ok {
+		t.log(res.Request.Context(), "digest: received challenge",
+			"host", res.Request.URL.Host,
+			"challenge", chal,
+		)
+	} else {
+		t.log(res.Request.Context(), "digest: received challenge",
+			"host", res.Request.URL.Host,
+		)
+	}
+	if !t.NoReuse {
+		t.add(host, cc, rejected)
+	}
+	return cc, nil
+}
+
+// add adds the challenge to the host's pool and removes the replaced
+// challenge if there is one. The caller must hold the cache lock.
+func (t *Transport) add(host string, cc, replaced *cchal) {
+	pool, ok := t.cache[host]
+	if !ok {
+		pool = &cpool{}
+		t.cache[host] = pool
+	}
+	if replaced != nil {
+		pool.remove(replaced)
+	}
+	pool.add(cc, max(t.PoolSize, 1))
+}
+
+// next replaces the cached challenge if the response
+// to an authorized request provided a new one
+func (t *Transport) next(res *http.Response, cc *cchal) {
+	ac := cc.a.Next(res)
+	if ac == nil || t.NoReuse {
+		return
+	}
+	t.cacheMu.Lock()
+	defer t.cacheMu.Unlock()
+	t.add(res.Request.URL.Hostname(), newCchal(ac), cc)
+}
+
+// authenticators returns the configured authenticators
+func (t *Transport) authenticators() []Authenticator {
+	if len(t.Authenticators) > 0 {
+		return t.Authenticators
 	}
-	t.cache[host] = &cchal{c: chal}
-	return nil
+	return []Authenticator{transportAuth{t: t}}
 }
 
 // digest creates credentials from the cached challenge
 func (t *Transport) digest(req *http.Request, chal *Challenge, count int) (*Credentials, error) {
-	opt := Options{
-		Method:   req.Method,
-		URI:      req.URL.RequestURI(),
-		GetBody:  req.GetBody,
-		Count:    count,
+	a := DigestAuth{
 		Username: t.Username,
 		Password: t.Password,
+		Digest:   t.Digest,
+		Policy:   t.Policy,
+		Quirks:   t.Quirks,
 	}
-	if t.Digest != nil {
-		return t.Digest(req, chal, opt)
+	cred, err := a.digest(req, chal, count)
+	if err == nil && cred != nil {
+		t.log(req.Context(), "digest: authorizing request",
+			"host", req.URL.Host,
+			"algorithm", cred.Algorithm,
+			"qop", cred.QOP,
+			"count", count,
+		)
 	}
-	return Digest(chal, opt)
+	return cred, err
 }
 
-// challenge returns a cached challenge and count for the provided request
-func (t *Transport) challenge(req *http.Request) (*Challenge, int, bool) {
+// format formats the credentials using the quirks
+func (t *Transport) format(cred *Credentials) string {
+	return cred.Format(t.Quirks)
+}
+
+// challenge returns the next cached challenge for the provided request.
+// If wait is false, the challenge is in use, and the pool isn't full, nil
+// is returned so that a new challenge is obtained.
+func (t *Transport) challenge(req *http.Request, wait bool) *cchal {
 	t.cacheMu.Lock()
 	defer t.cacheMu.Unlock()
-	host := req.URL.Hostname()
-	cc, ok := t.cache[host]
+	pool, ok := t.cache[req.URL.Hostname()]
 	if !ok {
-		return nil, 0, false
+		return nil
 	}
-	if t.NoReuse {
-		delete(t.cache, host)
+	// discard challenges which the server is expected to reject
+	var cc *cchal
+	for cc = pool.get(); cc != nil && t.expired(cc); cc = pool.get() {
+		pool.remove(cc)
+	}
+	if cc != nil && !wait && t.Serialize && len(pool.cc) < t.PoolSize && len(cc.sem) > 0 {
+		return nil
+	}
+	return cc
+}
+
+// expired returns true if the challenge has exceeded the configured limits.
+// The caller must hold the cache lock.
+func (t *Transport) expired(cc *cchal) bool {
+	if t.MaxNonceUses > 0 && cc.n >= t.MaxNonceUses {
+		return true
+	}
+	return t.MaxNonceAge > 0 && time.Since(cc.t) > t.MaxNonceAge
+}
+
+// use returns the next count for the challenge. When Serialize is enabled, it
+// waits until the challenge isn't in use or the request's context is done.
+// The returned unlock function must be called once the request has been sent.
+func (t *Transport) use(req *http.Request, cc *cchal) (int, func(), error) {
+	unlock := func() {}
+	if t.Serialize {
+		select {
+		case cc.sem <- struct{}{}:
+			unlock = func() { <-cc.sem }
+		case <-req.Context().Done():
+			return 0, nil, req.Context().Err()
+		}
 	}
+	t.cacheMu.Lock()
+	defer t.cacheMu.Unlock()
 	cc.n++
-	return cc.c, cc.n, true
+	return cc.n, unlock, nil
 }
 
-// prepare attempts to find a cached challenge that matches the
-// requested domain, and use it to set the Authorization header
-func (t *Transport) prepare(req *http.Request) error {
+// prepare uses the provided challenge to set the Authorization header. If cc is nil,
+// it attempts to find a cached challenge that matches the requested domain.
+// The challenge and count are returned if one was used.
+// The returned unlock function must be called once the request has been sent.
+func (t *Transport) prepare(req *http.Request, cc *cchal) (*cchal, int, func(), error) {
 	// add cookies
 	if t.Jar != nil {
 		for _, cookie := range t.Jar.Cookies(req.URL) {
@@ -124,18 +352,44 @@ func (t *Transport) prepare(req *http.Request) error {
 		}
 	}
 	// add auth
-	chal, count, ok := t.challenge(req)
-	if !ok {
-		return nil
+	if cc == nil && t.Origins.allows(req) {
+		cc = t.challenge(req, false)
+	}
+	if cc == nil {
+		return nil, 0, func() {}, nil
 	}
-	cred, err := t.digest(req, chal, count)
+	count, unlock, err := t.use(req, cc)
 	if err != nil {
-		return err
+		return nil, 0, nil, err
+	}
+	if err := cc.a.Authorize(req, count); err != nil {
+		unlock()
+		t.log(req.Context(), "digest: failed to authorize request",
+			"host", req.URL.Host,
+			"error", err,
+		)
+		return nil, 0, nil, err
+	}
+	return cc, count, unlock, nil
+}
+
+// log writes a debug message to the logger if there is one
+func (t *Transport) log(ctx context.Context, msg string, args ...any) {
+	if t.Logger != nil {
+		t.Logger.DebugContext(ctx, msg, args...)
+	}
+}
+
+// traces returns the trace hooks for the request
+func (t *Transport) traces(req *http.Request) traces {
+	var tt traces
+	if t.Trace != nil {
+		tt = append(tt, t.Trace)
 	}
-	if cred != nil {
-		req.Header.Set("Authorization", cred.String())
+	if trace := ContextClientTrace(req.Context()); trace != nil {
+		tt = append(tt, trace)
 	}
-	return nil
+	return tt
 }
 
 // RoundTrip will try to authorize the request using a cached challenge.
@@ -146,6 +400,10 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	if tr == nil {
 		tr = http.DefaultTransport
 	}
+	// stream bodies which can't be replayed
+	if t.ProbeMethod != "" && req.GetBody == nil && req.Body != nil && req.Body != http.NoBody {
+		return t.stream(tr, req)
+	}
 	// don't modify the original request
 	clone, err := cloner(req)
 	if err != nil {
@@ -156,35 +414,154 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	if err != nil {
 		return nil, err
 	}
+	trace := t.traces(req)
 	// prepare the first request using a cached challenge
-	if err := t.prepare(first); err != nil {
+	cc, count, unlock, err := t.prepare(first, nil)
+	if err != nil {
 		return nil, err
 	}
+	if cc == nil {
+		trace.cacheMiss(first)
+	} else if chal, ok := cc.digest(); ok {
+		trace.cacheHit(first, chal, count)
+	}
 	// the first request will either succeed or return a 401
 	res, err := tr.RoundTrip(first)
-	if err != nil || res.StatusCode != http.StatusUnauthorized {
-		return res, err
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusUnauthorized {
+		if cc != nil {
+			t.next(res, cc)
+		}
+		return res, nil
 	}
 	// drain and close the first message body
 	_, _ = io.Copy(io.Discard, res.Body)
 	_ = res.Body.Close()
 	// save the challenge for future use
-	if err := t.save(res); err != nil {
-		if err == ErrNoChallenge {
+	cc, err = t.save(res, cc)
+	if err != nil {
+		t.log(req.Context(), "digest: no usable challenge",
+			"host", req.URL.Host,
+			"error", err,
+		)
+		trace.authFailure(first, res, err)
+		if err == ErrNoChallenge || err == ErrUntrustedOrigin {
 			return res, nil
 		}
 		return nil, err
 	}
+	if chal, ok := cc.digest(); ok {
+		trace.challenge(first, chal)
+	}
 	// make a second copy of the request
 	second, err := clone()
 	if err != nil {
 		return nil, err
 	}
 	// prepare the second request based on the new challenge
-	if err := t.prepare(second); err != nil {
+	_, _, unlock, err = t.prepare(second, cc)
+	if err != nil {
 		return nil, err
 	}
-	return tr.RoundTrip(second)
+	trace.retry(second, res)
+	res, err = tr.RoundTrip(second)
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusUnauthorized {
+		t.log(req.Context(), "digest: credentials rejected",
+			"host", req.URL.Host,
+		)
+		trace.authFailure(second, res, nil)
+	} else {
+		t.next(res, cc)
+	}
+	return res, nil
+}
+
+// stream sends a request without buffering its body. If there's no cached
+// challenge, a probe request is used to obtain one first.
+func (t *Transport) stream(tr http.RoundTripper, req *http.Request) (*http.Response, error) {
+	if t.NoReuse {
+		return nil, errors.New("digest: ProbeMethod cannot be used with NoReuse")
+	}
+	var cc *cchal
+	if t.Origins.allows(req) {
+		cc = t.challenge(req, true)
+		if cc == nil {
+			if err := t.probe(req); err != nil {
+				return nil, err
+			}
+			cc = t.challenge(req, true)
+		}
+	}
+	if cc != nil {
+		if chal, ok := cc.digest(); ok && !chal.SupportsQOP("auth") && chal.SupportsQOP("auth-int") {
+			return nil, errors.New("digest: ProbeMethod cannot be used with auth-int challenges")
+		}
+	}
+	stream := req.Clone(req.Context())
+	trace := t.traces(req)
+	cc, count, unlock, err := t.prepare(stream, cc)
+	if err != nil {
+		return nil, err
+	}
+	if cc == nil {
+		trace.cacheMiss(stream)
+	} else if chal, ok := cc.digest(); ok {
+		trace.cacheHit(stream, chal, count)
+	}
+	res, err := tr.RoundTrip(stream)
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusUnauthorized {
+		if cc != nil {
+			t.next(res, cc)
+		}
+		return res, nil
+	}
+	// the body has been consumed so the request can't be retried,
+	// but the challenge can be used by future requests
+	next, err := t.save(res, cc)
+	if err != nil {
+		t.log(req.Context(), "digest: no usable challenge",
+			"host", req.URL.Host,
+			"error", err,
+		)
+		trace.authFailure(stream, res, err)
+		return res, nil
+	}
+	if chal, ok := next.digest(); ok {
+		trace.challenge(stream, chal)
+	}
+	t.log(req.Context(), "digest: credentials rejected",
+		"host", req.URL.Host,
+	)
+	trace.authFailure(stream, res, nil)
+	return res, nil
+}
+
+// probe sends a request without a body using the ProbeMethod
+// so that the challenge is cached
+func (t *Transport) probe(req *http.Request) error {
+	probe, err := http.NewRequestWithContext(req.Context(), t.ProbeMethod, req.URL.String(), nil)
+	if err != nil {
+		return err
+	}
+	probe.Header = req.Header.Clone()
+	probe.Response = req.Response
+	res, err := t.RoundTrip(probe)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	return res.Body.Close()
 }
 
 // CloseIdleConnections delegates the call to the underlying transport.
@@ -1,8 +1,10 @@
 package digest
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -149,3 +151,102 @@ func TestTransportNoChallenge(t *testing.T) {
 	assert.NilError(t, err)
 	assert.Equal(t, res2.StatusCode, http.StatusUnauthorized)
 }
+
+func TestTransportLogger(t *testing.T) {
+	chal := &Challenge{
+		Realm:     "test",
+		Nonce:     "jgdfsijdfisd",
+		Algorithm: "SHA-256",
+		QOP:       []string{"auth"},
+	}
+	var responses []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			cred, err := ParseCredentials(auth)
+			if !assert.Check(t, err) {
+				return
+			}
+			responses = append(responses, cred.Response)
+			return
+		}
+		w.Header().Add("WWW-Authenticate", chal.String())
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+	var buf bytes.Buffer
+	client := http.Client{
+		Transport: &Transport{
+			Username: "foo",
+			Password: "secret-password",
+			Logger:   slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		},
+	}
+	for range 2 {
+		res, err := client.Get(ts.URL)
+		assert.NilError(t, err)
+		res.Body.Close()
+	}
+	logs := buf.String()
+	assert.Assert(t, strings.Contains(logs, `msg="digest: received challenge"`), logs)
+	assert.Assert(t, strings.Contains(logs, "challenge.algorithm=SHA-256"), logs)
+	assert.Assert(t, strings.Contains(logs, "algorithm=SHA-256 qop=auth count=2"), logs)
+	assert.Assert(t, !strings.Contains(logs, "secret-password"), logs)
+	assert.Assert(t, !strings.Contains(logs, chal.Nonce), logs)
+	assert.Equal(t, len(responses), 2)
+	for _, response := range responses {
+		assert.Assert(t, !strings.Contains(logs, response), logs)
+	}
+}
+
+func TestTransportBasic(t *testing.T) {
+	handler := func(challenges *int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if username, password, ok := r.BasicAuth(); ok && username == "foo" && password == "bar" {
+				io.WriteString(w, "Hello World")
+				return
+			}
+			*challenges++
+			w.Header().Add("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+	}
+	tests := []struct {
+		name       string
+		tls        bool
+		policy     BasicPolicy
+		status     int
+		challenges int
+	}{
+		{name: "Never", tls: true, policy: BasicNever, status: http.StatusUnauthorized, challenges: 2},
+		{name: "TLS", tls: true, policy: BasicTLS, status: http.StatusOK, challenges: 1},
+		{name: "TLSPlaintext", tls: false, policy: BasicTLS, status: http.StatusUnauthorized, challenges: 2},
+		{name: "Always", tls: false, policy: BasicAlways, status: http.StatusOK, challenges: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var challenges int
+			ts := httptest.NewUnstartedServer(handler(&challenges))
+			if tt.tls {
+				ts.StartTLS()
+			} else {
+				ts.Start()
+			}
+			defer ts.Close()
+			client := http.Client{
+				Transport: &Transport{
+					Username:  "foo",
+					Password:  "bar",
+					Basic:     tt.policy,
+					Transport: ts.Client().Transport,
+				},
+			}
+			for range 2 {
+				res, err := client.Get(ts.URL)
+				assert.NilError(t, err)
+				res.Body.Close()
+				assert.Equal(t, res.StatusCode, tt.status)
+			}
+			assert.Equal(t, challenges, tt.challenges)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package digest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// Upgrade sends a protocol upgrade request, such as a WebSocket handshake,
+// and answers any digest challenge. The request must use the http or https
+// scheme and contain the Connection and Upgrade headers. If the server switches
+// protocols, the upgraded connection is returned and it must be closed by the caller.
+// Otherwise, the response is returned with a nil connection, and the caller must
+// close its body.
+func (t *Transport) Upgrade(req *http.Request) (*http.Response, io.ReadWriteCloser, error) {
+	res, err := t.RoundTrip(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		return res, nil, nil
+	}
+	conn, ok := res.Body.(io.ReadWriteCloser)
+	if !ok {
+		_ = res.Body.Close()
+		return nil, nil, errors.New("digest: upgraded connection is not writable")
+	}
+	return res, conn, nil
+}
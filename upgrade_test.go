@@ -0,0 +1,73 @@
+package digest_test
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/icholy/digest"
+	"github.com/icholy/digest/digesttest"
+	"gotest.tools/v3/assert"
+)
+
+func TestTransportUpgrade(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+		_, _ = io.Copy(conn, brw)
+	})
+	s := digesttest.NewServer(echo, map[string]string{"foo": "bar"})
+	defer s.Close()
+	tr := &digest.Transport{
+		Username: "foo",
+		Password: "bar",
+	}
+	req, err := http.NewRequest(http.MethodGet, s.URL+"/events", nil)
+	assert.NilError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	res, conn, err := tr.Upgrade(req)
+	assert.NilError(t, err)
+	defer conn.Close()
+	assert.Equal(t, res.StatusCode, http.StatusSwitchingProtocols)
+	assert.Equal(t, s.Challenges(), 1)
+	_, err = io.WriteString(conn, "ping\n")
+	assert.NilError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NilError(t, err)
+	assert.Equal(t, line, "ping\n")
+}
+
+func TestTransportUpgradeFailed(t *testing.T) {
+	s := digesttest.NewServer(nil, map[string]string{"foo": "bar"})
+	defer s.Close()
+	tr := &digest.Transport{
+		Username: "foo",
+		Password: "wrong",
+	}
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	assert.NilError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	res, conn, err := tr.Upgrade(req)
+	assert.NilError(t, err)
+	defer res.Body.Close()
+	assert.Assert(t, conn == nil)
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+	body, err := io.ReadAll(res.Body)
+	assert.NilError(t, err)
+	assert.Equal(t, string(body), "Unauthorized\n")
+}
@@ -0,0 +1,150 @@
+package digest
+
+import (
+	"net/http"
+	"strings"
+)
+
+// MatchURI returns true if the uri parameter of the credentials refers to the
+// same resource as the request. RFC 7616 requires servers to check this so that
+// credentials computed for one resource can't be replayed for another.
+//
+// The uri may be in origin-form (/path?query), absolute-form (http://host/path?query),
+// or authority-form (host:port) for CONNECT requests. Percent-encoded unreserved
+// characters are decoded before comparing, and hex digits are case-insensitive.
+// The default port of the scheme is ignored when comparing hosts. The query must match.
+func MatchURI(uri string, r *http.Request) bool {
+	target := r.RequestURI
+	if target == "" {
+		if r.Method == http.MethodConnect {
+			target = r.URL.Host
+		} else {
+			target = r.URL.RequestURI()
+		}
+	}
+	return matchURI(uri, target, r.Host)
+}
+
+// reqTarget is a parsed request-target
+type reqTarget struct {
+	scheme    string
+	authority string
+	path      string
+	query     string
+	hasQuery  bool
+}
+
+// parseTarget parses a request-target. The ok return value is false
+// for the authority-form.
+func parseTarget(s string) (t reqTarget, ok bool) {
+	if scheme, rest, found := strings.Cut(s, "://"); found && !strings.Contains(scheme, "/") {
+		t.scheme = strings.ToLower(scheme)
+		end := strings.IndexAny(rest, "/?")
+		if end < 0 {
+			end = len(rest)
+		}
+		t.authority, s = rest[:end], rest[end:]
+	} else if !strings.HasPrefix(s, "/") {
+		return reqTarget{authority: s}, false
+	}
+	t.path, t.query, t.hasQuery = strings.Cut(s, "?")
+	if t.path == "" {
+		t.path = "/"
+	}
+	return t, true
+}
+
+// matchURI compares the uri parameter to the request-target. The host is used when
+// only one of them is in absolute-form. If the host is empty, the authority is
+// only compared when both are in absolute-form.
+func matchURI(uri, target, host string) bool {
+	if uri == target {
+		return true
+	}
+	if uri == "*" || target == "*" {
+		return false
+	}
+	u, uok := parseTarget(uri)
+	t, tok := parseTarget(target)
+	// authority-form is used by CONNECT requests
+	if !uok || !tok {
+		return !uok && !tok && strings.EqualFold(u.authority, t.authority)
+	}
+	if u.scheme != "" && t.scheme != "" && u.scheme != t.scheme {
+		return false
+	}
+	ua, ta := u.authority, t.authority
+	if u.scheme == "" {
+		ua = host
+	}
+	if t.scheme == "" {
+		ta = host
+	}
+	scheme := u.scheme
+	if scheme == "" {
+		scheme = t.scheme
+	}
+	if ua != "" && ta != "" && !strings.EqualFold(trimDefaultPort(ua, scheme), trimDefaultPort(ta, scheme)) {
+		return false
+	}
+	return normalizeEscapes(u.path) == normalizeEscapes(t.path) &&
+		u.hasQuery == t.hasQuery &&
+		normalizeEscapes(u.query) == normalizeEscapes(t.query)
+}
+
+// trimDefaultPort removes the scheme's default port from the authority
+func trimDefaultPort(authority, scheme string) string {
+	switch scheme {
+	case "http":
+		return strings.TrimSuffix(authority, ":80")
+	case "https":
+		return strings.TrimSuffix(authority, ":443")
+	default:
+		return authority
+	}
+}
+
+// normalizeEscapes decodes percent-encoded unreserved characters
+// and upper cases the hex digits of the remaining escapes.
+func normalizeEscapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			b.WriteByte(s[i])
+			continue
+		}
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// isUnreserved returns true for the RFC 3986 unreserved characters
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
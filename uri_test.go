@@ -0,0 +1,191 @@
+package digest
+
+import (
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestMatchURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		method  string
+		target  string
+		host    string
+		matches bool
+	}{
+		{
+			name:    "origin-form",
+			uri:     "/dir/index.html",
+			target:  "/dir/index.html",
+			host:    "example.com",
+			matches: true,
+		},
+		{
+			name:    "different path",
+			uri:     "/dir/index.html",
+			target:  "/dir/other.html",
+			host:    "example.com",
+			matches: false,
+		},
+		{
+			name:    "query",
+			uri:     "/search?q=1",
+			target:  "/search?q=1",
+			host:    "example.com",
+			matches: true,
+		},
+		{
+			name:    "missing query",
+			uri:     "/search",
+			target:  "/search?q=1",
+			host:    "example.com",
+			matches: false,
+		},
+		{
+			name:    "different query",
+			uri:     "/search?q=1",
+			target:  "/search?q=2",
+			host:    "example.com",
+			matches: false,
+		},
+		{
+			name:    "absolute-form uri",
+			uri:     "http://example.com/dir/index.html",
+			target:  "/dir/index.html",
+			host:    "example.com",
+			matches: true,
+		},
+		{
+			name:    "absolute-form uri with other host",
+			uri:     "http://other.com/dir/index.html",
+			target:  "/dir/index.html",
+			host:    "example.com",
+			matches: false,
+		},
+		{
+			name:    "absolute-form target",
+			uri:     "/dir/index.html",
+			target:  "http://example.com/dir/index.html",
+			host:    "example.com",
+			matches: true,
+		},
+		{
+			name:    "absolute-form both",
+			uri:     "HTTP://Example.com/dir/index.html",
+			target:  "http://example.com/dir/index.html",
+			host:    "example.com",
+			matches: true,
+		},
+		{
+			name:    "absolute-form scheme mismatch",
+			uri:     "https://example.com/dir/index.html",
+			target:  "http://example.com/dir/index.html",
+			host:    "example.com",
+			matches: false,
+		},
+		{
+			name:    "absolute-form uri with default http port",
+			uri:     "http://example.com:80/dir/index.html",
+			target:  "/dir/index.html",
+			host:    "example.com",
+			matches: true,
+		},
+		{
+			name:    "absolute-form uri with default https port",
+			uri:     "https://example.com:443/dir/index.html",
+			target:  "/dir/index.html",
+			host:    "example.com",
+			matches: true,
+		},
+		{
+			name:    "host with default port",
+			uri:     "http://example.com/dir/index.html",
+			target:  "/dir/index.html",
+			host:    "example.com:80",
+			matches: true,
+		},
+		{
+			name:    "absolute-form both with default port",
+			uri:     "http://example.com:80/dir/index.html",
+			target:  "http://example.com/dir/index.html",
+			host:    "example.com",
+			matches: true,
+		},
+		{
+			name:    "absolute-form uri with other scheme's default port",
+			uri:     "http://example.com:443/dir/index.html",
+			target:  "/dir/index.html",
+			host:    "example.com",
+			matches: false,
+		},
+		{
+			name:    "absolute-form uri with other port",
+			uri:     "http://example.com:8080/dir/index.html",
+			target:  "/dir/index.html",
+			host:    "example.com",
+			matches: false,
+		},
+		{
+			name:    "percent-encoded unreserved",
+			uri:     "/%7Euser/a%2db",
+			target:  "/~user/a-b",
+			host:    "example.com",
+			matches: true,
+		},
+		{
+			name:    "percent-encoded case",
+			uri:     "/a%2fb",
+			target:  "/a%2Fb",
+			host:    "example.com",
+			matches: true,
+		},
+		{
+			name:    "percent-encoded reserved",
+			uri:     "/a%2Fb",
+			target:  "/a/b",
+			host:    "example.com",
+			matches: false,
+		},
+		{
+			name:    "connect",
+			uri:     "Example.com:443",
+			method:  http.MethodConnect,
+			target:  "example.com:443",
+			host:    "example.com:443",
+			matches: true,
+		},
+		{
+			name:    "connect mismatch",
+			uri:     "/",
+			method:  http.MethodConnect,
+			target:  "example.com:443",
+			host:    "example.com:443",
+			matches: false,
+		},
+		{
+			name:    "asterisk",
+			uri:     "*",
+			method:  http.MethodOptions,
+			target:  "*",
+			host:    "example.com",
+			matches: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := &http.Request{
+				Method:     method,
+				RequestURI: tt.target,
+				Host:       tt.host,
+			}
+			assert.Equal(t, MatchURI(tt.uri, r), tt.matches)
+		})
+	}
+}
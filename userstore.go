@@ -0,0 +1,97 @@
+package digest
+
+import "sync"
+
+// UserStore is a set of accounts which can be looked up by username or by
+// hashed username. Hashed usernames are indexed per algorithm and realm so that
+// servers can offer RFC 7616 username privacy. The zero value is ready to use.
+type UserStore struct {
+	mu        sync.RWMutex
+	passwords map[string]string
+	indexes   map[userIndex]map[string]string
+}
+
+// userIndex identifies the hashed usernames for an algorithm and realm
+type userIndex struct {
+	algorithm string
+	realm     string
+}
+
+// hash returns H(username:realm)
+func (i userIndex) hash(username string) (string, error) {
+	h, err := newHash(i.algorithm)
+	if err != nil {
+		return "", err
+	}
+	return hashjoin(h, username, i.realm), nil
+}
+
+// Add adds the user to the store or changes the password of an existing user
+func (s *UserStore) Add(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.passwords == nil {
+		s.passwords = map[string]string{}
+	}
+	s.passwords[username] = password
+	for index, hashes := range s.indexes {
+		if hash, err := index.hash(username); err == nil {
+			hashes[hash] = username
+		}
+	}
+}
+
+// Remove removes the user from the store
+func (s *UserStore) Remove(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.passwords, username)
+	for index, hashes := range s.indexes {
+		if hash, err := index.hash(username); err == nil {
+			delete(hashes, hash)
+		}
+	}
+}
+
+// Lookup returns the password for the username. It can be used as Handler.Lookup.
+func (s *UserStore) Lookup(username string) (password string, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	password, ok = s.passwords[username]
+	return password, ok
+}
+
+// LookupUserhash returns the username and password for a hashed username.
+// The index for the algorithm and realm is built the first time it's used.
+// It can be used as Handler.LookupUserhash.
+func (s *UserStore) LookupUserhash(userhash, realm, algorithm string) (username, password string, ok bool) {
+	index := userIndex{algorithm: canonical(algorithm), realm: realm}
+	s.mu.RLock()
+	hashes, indexed := s.indexes[index]
+	if indexed {
+		username, ok = hashes[userhash]
+		password = s.passwords[username]
+	}
+	s.mu.RUnlock()
+	if indexed {
+		return username, password, ok
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hashes, indexed = s.indexes[index]; !indexed {
+		hashes = map[string]string{}
+		for username := range s.passwords {
+			hash, err := index.hash(username)
+			if err != nil {
+				return "", "", false
+			}
+			hashes[hash] = username
+		}
+		if s.indexes == nil {
+			s.indexes = map[userIndex]map[string]string{}
+		}
+		s.indexes[index] = hashes
+	}
+	username, ok = hashes[userhash]
+	return username, s.passwords[username], ok
+}
@@ -0,0 +1,91 @@
+package digest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/icholy/digest"
+	"gotest.tools/v3/assert"
+)
+
+// userhash returns H(username:realm) as computed by a client
+func userhash(t *testing.T, username, realm, algorithm string) string {
+	t.Helper()
+	cred, err := digest.Digest(&digest.Challenge{
+		Realm:     realm,
+		Algorithm: algorithm,
+		Userhash:  true,
+	}, digest.Options{Username: username})
+	assert.NilError(t, err)
+	return cred.Username
+}
+
+func TestUserStore(t *testing.T) {
+	var s digest.UserStore
+	s.Add("alice", "secret")
+	password, ok := s.Lookup("alice")
+	assert.Assert(t, ok)
+	assert.Equal(t, password, "secret")
+	// the hashes depend on the algorithm and realm
+	for _, algorithm := range []string{"MD5", "SHA-256", "sha-512-256"} {
+		for _, realm := range []string{"a", "b"} {
+			username, password, ok := s.LookupUserhash(userhash(t, "alice", realm, algorithm), realm, algorithm)
+			assert.Assert(t, ok)
+			assert.Equal(t, username, "alice")
+			assert.Equal(t, password, "secret")
+		}
+	}
+	_, _, ok = s.LookupUserhash(userhash(t, "alice", "a", "SHA-256"), "b", "SHA-256")
+	assert.Assert(t, !ok)
+	// existing indexes are updated
+	s.Add("bob", "hunter2")
+	username, password, ok := s.LookupUserhash(userhash(t, "bob", "a", "SHA-256"), "a", "SHA-256")
+	assert.Assert(t, ok)
+	assert.Equal(t, username, "bob")
+	assert.Equal(t, password, "hunter2")
+	s.Remove("bob")
+	_, _, ok = s.LookupUserhash(userhash(t, "bob", "a", "SHA-256"), "a", "SHA-256")
+	assert.Assert(t, !ok)
+	_, ok = s.Lookup("bob")
+	assert.Assert(t, !ok)
+}
+
+func TestHandlerUserhash(t *testing.T) {
+	var store digest.UserStore
+	store.Add("alice", "secret")
+	var user *digest.User
+	ts := httptest.NewServer(&digest.Handler{
+		Realm:          "test",
+		Lookup:         store.Lookup,
+		Userhash:       true,
+		LookupUserhash: store.LookupUserhash,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, _ = digest.UserFromContext(r.Context())
+			assert.Check(t, r.Header.Get("Authorization") != "")
+		}),
+	})
+	defer ts.Close()
+	var sent string
+	client := &http.Client{
+		Transport: &digest.Transport{
+			Username: "alice",
+			Password: "secret",
+			Trace: &digest.ClientTrace{
+				OnRetry: func(req *http.Request, res *http.Response) {
+					cred, err := digest.ParseCredentials(req.Header.Get("Authorization"))
+					assert.NilError(t, err)
+					sent = cred.Username
+				},
+			},
+		},
+	}
+	getOK(t, client, ts.URL)
+	assert.Equal(t, sent, userhash(t, "alice", "test", "SHA-256"))
+	assert.DeepEqual(t, user, &digest.User{
+		Username:  "alice",
+		Realm:     "test",
+		Algorithm: "SHA-256",
+		Userhash:  true,
+	})
+}
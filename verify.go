@@ -0,0 +1,133 @@
+package digest
+
+import (
+	"crypto/subtle"
+	"fmt"
+)
+
+// Reason describes why credentials failed verification
+type Reason int
+
+const (
+	// ReasonRealm means the realm doesn't match the challenge
+	ReasonRealm Reason = iota + 1
+	// ReasonNonce means the nonce doesn't match the challenge
+	ReasonNonce
+	// ReasonOpaque means the opaque value doesn't match the challenge
+	ReasonOpaque
+	// ReasonAlgorithm means the algorithm doesn't match the challenge
+	ReasonAlgorithm
+	// ReasonQOP means the qop wasn't offered by the challenge
+	ReasonQOP
+	// ReasonURI means the uri doesn't identify the requested resource
+	ReasonURI
+	// ReasonUsername means the username doesn't match the account
+	ReasonUsername
+	// ReasonResponse means the response is incorrect
+	ReasonResponse
+)
+
+// String returns the name of the reason
+func (r Reason) String() string {
+	switch r {
+	case ReasonRealm:
+		return "realm mismatch"
+	case ReasonNonce:
+		return "nonce mismatch"
+	case ReasonOpaque:
+		return "opaque mismatch"
+	case ReasonAlgorithm:
+		return "algorithm mismatch"
+	case ReasonQOP:
+		return "unsupported qop"
+	case ReasonURI:
+		return "uri mismatch"
+	case ReasonUsername:
+		return "username mismatch"
+	case ReasonResponse:
+		return "incorrect response"
+	default:
+		return fmt.Sprintf("Reason(%d)", int(r))
+	}
+}
+
+// VerifyError is returned when credentials fail verification
+type VerifyError struct {
+	Reason Reason
+}
+
+// Error implements error
+func (e *VerifyError) Error() string {
+	return "digest: invalid credentials: " + e.Reason.String()
+}
+
+// Verify checks that the credentials answer the challenge. The options describe
+// the request the credentials were received with: the Method, the URI from the
+// request line, the GetBody function for auth-int, and the Username and Password
+// (or A1) of the account. The Count and Cnonce options are ignored. The uri parameter
+// is compared to the URI option in the same way as MatchURI, but the host is only
+// compared if both are in absolute-form.
+//
+// The response is compared in constant time. A *VerifyError is returned if the
+// credentials are rejected. Verify does not track nonce counts, so it's the
+// caller's responsibility to detect replayed credentials.
+func Verify(chal *Challenge, cred *Credentials, o Options) error {
+	if cred.Realm != chal.Realm {
+		return &VerifyError{Reason: ReasonRealm}
+	}
+	if cred.Nonce != chal.Nonce {
+		return &VerifyError{Reason: ReasonNonce}
+	}
+	if cred.Opaque != chal.Opaque {
+		return &VerifyError{Reason: ReasonOpaque}
+	}
+	if canonical(cred.Algorithm) != canonical(chal.Algorithm) {
+		return &VerifyError{Reason: ReasonAlgorithm}
+	}
+	if (len(chal.QOP) == 0 && cred.QOP != "") || (len(chal.QOP) != 0 && !chal.SupportsQOP(cred.QOP)) {
+		return &VerifyError{Reason: ReasonQOP}
+	}
+	if !matchURI(cred.URI, o.URI, "") {
+		return &VerifyError{Reason: ReasonURI}
+	}
+	if cred.Userhash && !chal.Userhash {
+		return &VerifyError{Reason: ReasonUsername}
+	}
+	// compute the expected response using the parameters selected by the client
+	expected, err := Digest(&Challenge{
+		Realm:     chal.Realm,
+		Nonce:     chal.Nonce,
+		Opaque:    chal.Opaque,
+		Algorithm: cred.Algorithm,
+		QOP:       qopList(cred.QOP),
+		Userhash:  cred.Userhash,
+	}, Options{
+		Method:   o.Method,
+		URI:      cred.URI,
+		GetBody:  o.GetBody,
+		Count:    cred.Nc,
+		Cnonce:   cred.Cnonce,
+		Username: o.Username,
+		Password: o.Password,
+		A1:       o.A1,
+		Policy:   o.Policy,
+	})
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(expected.Username), []byte(cred.Username)) != 1 {
+		return &VerifyError{Reason: ReasonUsername}
+	}
+	if subtle.ConstantTimeCompare([]byte(expected.Response), []byte(cred.Response)) != 1 {
+		return &VerifyError{Reason: ReasonResponse}
+	}
+	return nil
+}
+
+// qopList returns the qop as a list
+func qopList(qop string) []string {
+	if qop == "" {
+		return nil
+	}
+	return []string{qop}
+}
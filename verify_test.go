@@ -0,0 +1,101 @@
+package digest
+
+import (
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestVerify(t *testing.T) {
+	chal := &Challenge{
+		Realm:     "test",
+		Nonce:     "abc",
+		Opaque:    "xyz",
+		Algorithm: "SHA-256",
+		QOP:       []string{"auth", "auth-int"},
+		Userhash:  true,
+	}
+	opt := Options{
+		Method:   "GET",
+		URI:      "/dir/index.html",
+		Username: "foo",
+		Password: "bar",
+	}
+	tests := []struct {
+		name   string
+		modify func(c *Credentials, o *Options)
+		reason Reason
+	}{
+		{
+			name:   "valid",
+			modify: func(c *Credentials, o *Options) {},
+		},
+		{
+			name:   "realm",
+			modify: func(c *Credentials, o *Options) { c.Realm = "other" },
+			reason: ReasonRealm,
+		},
+		{
+			name:   "nonce",
+			modify: func(c *Credentials, o *Options) { c.Nonce = "other" },
+			reason: ReasonNonce,
+		},
+		{
+			name:   "opaque",
+			modify: func(c *Credentials, o *Options) { c.Opaque = "" },
+			reason: ReasonOpaque,
+		},
+		{
+			name:   "algorithm",
+			modify: func(c *Credentials, o *Options) { c.Algorithm = "MD5" },
+			reason: ReasonAlgorithm,
+		},
+		{
+			name:   "qop",
+			modify: func(c *Credentials, o *Options) { c.QOP = "" },
+			reason: ReasonQOP,
+		},
+		{
+			name:   "uri",
+			modify: func(c *Credentials, o *Options) { o.URI = "/other" },
+			reason: ReasonURI,
+		},
+		{
+			name:   "username",
+			modify: func(c *Credentials, o *Options) { o.Username = "other" },
+			reason: ReasonUsername,
+		},
+		{
+			name:   "password",
+			modify: func(c *Credentials, o *Options) { o.Password = "other" },
+			reason: ReasonResponse,
+		},
+		{
+			name:   "method",
+			modify: func(c *Credentials, o *Options) { o.Method = "POST" },
+			reason: ReasonResponse,
+		},
+		{
+			name:   "count",
+			modify: func(c *Credentials, o *Options) { c.Nc = 2 },
+			reason: ReasonResponse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cred, err := Digest(chal, opt)
+			assert.NilError(t, err)
+			o := opt
+			tt.modify(cred, &o)
+			err = Verify(chal, cred, o)
+			if tt.reason == 0 {
+				assert.NilError(t, err)
+				return
+			}
+			var verr *VerifyError
+			assert.Assert(t, errors.As(err, &verr))
+			assert.Equal(t, verr.Reason, tt.reason)
+		})
+	}
+}